@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -15,17 +16,22 @@ func runFile(vm *ligo.VM) {
 			fmt.Println(err)
 			return
 		}
+		ctx, cancel := context.WithCancel(context.Background())
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt)
 		go func() {
-			for range c {
-				vm.Stop()
-				os.Exit(0)
-			}
+			// first interrupt cancels the evaluation, the next one exits
+			<-c
+			cancel()
+			<-c
+			os.Exit(0)
 		}()
-		err = vm.LoadReader(f)
+		err = vm.LoadReaderContext(ctx, f)
+		signal.Stop(c)
+		cancel()
 		if err != nil {
 			fmt.Println(err)
+			fmt.Print(ligo.GetStackTrace(err))
 		}
 	}
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -25,9 +27,8 @@ func runInteractive(vm *ligo.VM) {
 	}
 	defer rl.Close()
 
-	running := false
-
-	go handleSignals(vm, &running)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
 
 	errorFmt := color.New(color.FgRed).Add(color.Bold)
 
@@ -51,6 +52,7 @@ func runInteractive(vm *ligo.VM) {
 			v, err := vm.Eval(part)
 			if err != nil {
 				fmt.Printf("Error in the expression passed : %s\n", errorFmt.Sprintf("%s", err))
+				fmt.Print(ligo.GetStackTrace(err))
 				rl.SetPrompt(getPrompt(vm))
 				continue
 			}
@@ -63,24 +65,20 @@ func runInteractive(vm *ligo.VM) {
 		expression += part
 		if ligo.MatchChars(strings.TrimSpace(expression), 0, '(', ')') > 0 {
 			rl.SetPrompt(getPrompt(vm))
-			running = true
-			v, err := vm.Eval(expression)
-			if err == ligo.ErrSignalRecieved {
+			v, err := evalInterruptible(vm, expression, sigs)
+			if errors.Is(err, ligo.ErrSignalRecieved) {
 				fmt.Printf("Caught Signal : %s\n", errorFmt.Sprintf("%s", err))
 				expression = ""
-				vm.Resume()
-				running = false
 				continue
 			}
 			if err != nil {
 				fmt.Printf("Error in the expression passed : %s\n\t %s\n", errorFmt.Sprintf("%s", err), expression)
+				fmt.Print(ligo.GetStackTrace(err))
 				expression = ""
-				running = false
 				continue
 			}
 			printValue(v)
 			expression = ""
-			running = false
 			continue
 		}
 		rl.SetPrompt("... ")
@@ -98,9 +96,9 @@ func loadRCFile(vm *ligo.VM) {
 
 func getPrompt(vm *ligo.VM) string {
 	defaultPrompt := ">>> "
-	ps1, ok := vm.Vars["PS1"]
-	if !ok {
-		vm.Vars["PS1"] = ligo.Variable{Type: ligo.TypeString, Value: defaultPrompt}
+	ps1, err := vm.GetVariable("PS1")
+	if err != nil {
+		vm.Define("PS1", ligo.Variable{Type: ligo.TypeString, Value: defaultPrompt})
 		return defaultPrompt
 	}
 	psraw, ok := ps1.Value.(string)
@@ -116,13 +114,22 @@ func printValue(v ligo.Variable) {
 	}
 }
 
-func handleSignals(vm *ligo.VM, running *bool) {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for sig := range c {
-		if *running {
+// evalInterruptible evaluates the expression, cancelling the evaluation
+// when a signal is received on sigs while it is running
+func evalInterruptible(vm *ligo.VM, expression string, sigs chan os.Signal) (ligo.Variable, error) {
+	select {
+	case <-sigs:
+	default:
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case sig := <-sigs:
 			fmt.Fprintln(os.Stderr, sig)
-			vm.Stop()
+			cancel()
+		case <-ctx.Done():
 		}
-	}
+	}()
+	return vm.EvalContext(ctx, expression)
 }
@@ -39,6 +39,7 @@ func main() {
 	vm.Funcs["require"] = VMRequire
 	vm.Funcs["load-plugin"] = VMDlLoad
 	vm.Funcs["exit"] = vmExit
+	vm.SetPackageLoader(loadPackageFiles)
 	if len(os.Args) < 1 {
 		runInteractive(vm)
 		return
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"plugin"
+	"sync"
 
 	"github.com/aki237/ligo/pkg/ligo"
 )
@@ -14,17 +15,17 @@ import (
 // The package system is still not finalized
 func VMRequire(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	if len(a) != 1 {
-		panic("require : wrong number of arguments")
+		return vm.Throw("require : wrong number of arguments")
 	}
 	lib := a[0]
 	if lib.Type != ligo.TypeString {
-		panic("require : expected a string, got " + lib.GetTypeString())
+		return vm.Throw("require : expected a string, got " + lib.GetTypeString())
 	}
 
 	packageName := lib.Value.(string)
 	err := LoadPackage(vm, packageName)
 	if err != nil {
-		panic(err)
+		return vm.Throw("require : " + err.Error())
 	}
 	return ligo.Variable{Type: ligo.TypeNil, Value: nil}
 }
@@ -32,21 +33,24 @@ func VMRequire(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 // VMDlLoad function is a ligo.InBuilt function that is used to load a package that is a dynamically loadable
 func VMDlLoad(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	if len(a) != 1 {
-		panic("load-plugin can only take one argument")
+		return vm.Throw("load-plugin : can only take one argument")
+	}
+	if a[0].Type != ligo.TypeString {
+		return vm.Throw("load-plugin : expected a string, got " + a[0].GetTypeString())
 	}
 
 	libpath := a[0].Value.(string)
 
 	p, err := plugin.Open(libpath)
 	if err != nil {
-		panic(err)
+		return vm.Throw("load-plugin : " + err.Error())
 	}
 	init, err := p.Lookup("PluginInit")
 	if err != nil {
-		panic(err)
+		return vm.Throw("load-plugin : " + err.Error())
 	}
 
-	init.(func(*ligo.VM))(vm)
+	vm.LoadPlugin(init.(func(*ligo.VM)))
 
 	return ligo.Variable{Type: ligo.TypeNil, Value: nil}
 }
@@ -60,18 +64,22 @@ func exists(dir string) bool {
 
 var packages = make([]string, 0)
 
+// packagesLock guards the list of the loaded packages, as the packages can be
+// required from the forked evaluations at the same time
+var packagesLock sync.Mutex
+
+// loaded function reports whether the package of the passed name is loaded already
+func loaded(packageName string) bool {
+	packagesLock.Lock()
+	defer packagesLock.Unlock()
+	return slistContains(packages, packageName)
+}
+
 // LoadPackage is used to load a package of the name "packageName" and load the functions and others in the passed ligo.VM.
 func LoadPackage(vm *ligo.VM, packageName string) error {
-	if slistContains(packages, packageName) {
+	if loaded(packageName) {
 		return nil
 	}
-	home := os.Getenv("HOME")
-
-	// Either /home/$USER or $LIGOPATH can be a path for library searching
-	if ligopath := os.Getenv("LIGOPATH"); ligopath != "" {
-		home = ligopath
-	}
-
 	packageNameSpace := filepath.Base(packageName)
 
 	tvm := vm
@@ -79,6 +87,28 @@ func LoadPackage(vm *ligo.VM, packageName string) error {
 		tvm = vm.CreateNamespace(packageNameSpace)
 	}
 
+	if err := loadPackageFiles(tvm, packageName); err != nil {
+		return err
+	}
+	packagesLock.Lock()
+	if !slistContains(packages, packageName) {
+		packages = append(packages, packageName)
+	}
+	packagesLock.Unlock()
+	return nil
+}
+
+// loadPackageFiles function is used to load the files of the package of the
+// name "packageName" in the passed ligo.VM. It is the ligo.PackageLoader used by
+// import, which loads the package in the scope of the module.
+func loadPackageFiles(vm *ligo.VM, packageName string) error {
+	home := os.Getenv("HOME")
+
+	// Either /home/$USER or $LIGOPATH can be a path for library searching
+	if ligopath := os.Getenv("LIGOPATH"); ligopath != "" {
+		home = ligopath
+	}
+
 	dir := filepath.Join(home, "ligo", "lib", packageName)
 	if !exists(dir) {
 		return ligo.Error("Package \"" + packageName + "\" not found in the system")
@@ -89,7 +119,7 @@ func LoadPackage(vm *ligo.VM, packageName string) error {
 
 	fileInfos, err := ioutil.ReadDir(dir)
 	if err != nil {
-		panic("require : " + fmt.Sprint(err))
+		return ligo.Error("require : " + fmt.Sprint(err))
 	}
 	for _, val := range fileInfos {
 		if val.IsDir() {
@@ -99,14 +129,14 @@ func LoadPackage(vm *ligo.VM, packageName string) error {
 			if filepath.Ext(val.Name()) == ".plg" {
 				p, err := plugin.Open(filepath.Join(dir, val.Name()))
 				if err != nil {
-					panic(err)
+					return err
 				}
 				init, err := p.Lookup("PluginInit")
 				if err != nil {
-					panic(err)
+					return err
 				}
 
-				init.(func(*ligo.VM))(tvm)
+				vm.LoadPlugin(init.(func(*ligo.VM)))
 			}
 			continue
 		}
@@ -114,12 +144,12 @@ func LoadPackage(vm *ligo.VM, packageName string) error {
 		if err != nil {
 			return err
 		}
-		err = tvm.LoadReader(file)
+		err = vm.LoadReader(file)
+		file.Close()
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
-	packages = append(packages, packageName)
 	return nil
 }
 
@@ -133,7 +163,6 @@ func slistContains(sl []string, s string) bool {
 }
 
 func vmExit(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
-	vm.Stop()
 	os.Exit(0)
 	return ligo.Variable{Type: ligo.TypeNil, Value: nil}
 }
@@ -6,7 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"runtime"
+	"sort"
 	"time"
 
 	"github.com/aki237/ligo/pkg/ligo"
@@ -15,7 +15,7 @@ import (
 // PluginInit function is the plugin initializer for the base package
 func PluginInit(vm *ligo.VM) {
 	vm.Funcs["println"] = vmPrintln
-	vm.Funcs["vmmem"] = vmMem
+	vm.Funcs["vm-stats"] = vmStats
 	vm.Funcs["input"] = vmInput
 	vm.Funcs["input-lines"] = vmInputLines
 	vm.Funcs["array-index"] = vmArrayIndex
@@ -37,8 +37,6 @@ func PluginInit(vm *ligo.VM) {
 	vm.Funcs["array-set"] = vmArraySet
 	vm.Funcs["array-subArray"] = vmArraySubArray
 	vm.Funcs["array-append"] = vmArrayAppend
-	vm.Funcs["or"] = vmOr
-	vm.Funcs["and"] = vmAnd
 	vm.Funcs["not"] = vmNot
 	vm.Funcs["is-nil"] = vmIsNil
 	vm.Funcs["sprintf"] = vmSprintf
@@ -61,7 +59,7 @@ func vmMapStore(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 		return vm.Throw("map-store : expected a <Map> type as the first argument")
 	}
 
-	a[0].Value.(ligo.Map)[a[1]] = a[2]
+	a[0].Value.(ligo.Map)[ligo.MapKey(a[1])] = a[2]
 	return a[0]
 }
 
@@ -74,7 +72,7 @@ func vmMapDelete(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 		return vm.Throw("map-delete : expected a <Map> type as the first argument")
 	}
 
-	delete(a[0].Value.(ligo.Map), a[1])
+	delete(a[0].Value.(ligo.Map), ligo.MapKey(a[1]))
 	return a[0]
 }
 
@@ -87,18 +85,35 @@ func vmMapGet(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 		return vm.Throw("map-get : expected a <Map> type as the first argument")
 	}
 
-	v, ok := a[0].Value.(ligo.Map)[a[1]]
+	v, ok := a[0].Value.(ligo.Map)[ligo.MapKey(a[1])]
 	if !ok {
 		return ligo.Variable{Type: ligo.TypeNil, Value: nil}
 	}
 	return v
 }
 
-func vmMem(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
-	mem := &runtime.MemStats{}
-	runtime.ReadMemStats(mem)
-	fmt.Println(float64(mem.Alloc) / (1024.0 * 1024.0))
-	return ligo.Variable{Type: ligo.TypeNil, Value: nil}
+// vmStats returns the statistics of the VM as a struct
+func vmStats(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
+	if len(a) != 0 {
+		return vm.Throw("vm-stats : expects no arguments")
+	}
+	st := vm.Stats()
+	usage := func(u ligo.Usage) ligo.Variable {
+		return ligo.Variable{Type: ligo.TypeStruct, Value: map[string]ligo.Variable{
+			"Steps": {Type: ligo.TypeInt, Value: u.Steps},
+			"Calls": {Type: ligo.TypeInt, Value: u.Calls},
+			"Bytes": {Type: ligo.TypeInt, Value: u.Bytes},
+		}}
+	}
+	return ligo.Variable{Type: ligo.TypeStruct, Value: map[string]ligo.Variable{
+		"Last":        usage(st.Last),
+		"Total":       usage(st.Total),
+		"MemoryLimit": {Type: ligo.TypeInt, Value: st.MemoryLimit},
+		"Vars":        {Type: ligo.TypeInt, Value: int64(st.Vars)},
+		"Funcs":       {Type: ligo.TypeInt, Value: int64(st.Funcs)},
+		"Namespaces":  {Type: ligo.TypeInt, Value: int64(st.Namespaces)},
+		"HeapAlloc":   {Type: ligo.TypeInt, Value: int64(st.HeapAlloc)},
+	}}
 }
 
 func vmArraySubArray(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
@@ -257,38 +272,6 @@ func collectVars(a []ligo.Variable) []interface{} {
 	return values
 }
 
-func vmOr(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
-	if len(a) < 1 {
-		return vm.Throw("or : expected atleast one argument")
-	}
-
-	resultBool := true
-	for _, val := range a {
-		if a[0].Type != ligo.TypeBool {
-			return vm.Throw("or : expected only boolean arguments, got " + val.GetTypeString())
-		}
-
-		resultBool = resultBool || val.Value.(bool)
-	}
-	return ligo.Variable{Type: ligo.TypeBool, Value: resultBool}
-}
-
-func vmAnd(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
-	if len(a) < 1 {
-		return vm.Throw("and : expected atleast one argument")
-	}
-
-	resultBool := true
-	for _, val := range a {
-		if a[0].Type != ligo.TypeBool {
-			return vm.Throw("and : expected only boolean arguments, got " + val.GetTypeString())
-		}
-
-		resultBool = resultBool && val.Value.(bool)
-	}
-	return ligo.Variable{Type: ligo.TypeBool, Value: resultBool}
-}
-
 func vmNot(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	if len(a) != 1 {
 		return vm.Throw(fmt.Sprintf("not : expected one argument, got %d argument(s)", len(a)))
@@ -314,7 +297,7 @@ func vmArrayAppend(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 		str := a[0].Value.(string)
 		for _, val := range a[1:] {
 			if val.Type == ligo.TypeInt {
-				str += string(val.Value.(int64))
+				str += string(rune(val.Value.(int64)))
 				continue
 			}
 			if val.Type == ligo.TypeString {
@@ -495,7 +478,7 @@ func vmEquality(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 		return vm.Throw(fmt.Sprintf("Equality can be done for 2 Values of same types only : found %s and %s, %s %s",
 			a[0].GetTypeString(), a[1].GetTypeString(), a[0], a[1]))
 	}
-	return ligo.Variable{Type: ligo.TypeBool, Value: a[0] == a[1]}
+	return ligo.Variable{Type: ligo.TypeBool, Value: ligo.Equal(a[0], a[1])}
 }
 
 func vmModulus(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
@@ -535,22 +518,70 @@ func vmPrint(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 				fmt.Print(key.Value, ":", value.Value, ";")
 			}
 			fmt.Print("}")
+		case val.Type == ligo.TypeException:
+			fmt.Print(val.Value)
+		case val.Type == ligo.TypeSymbol, val.Type == ligo.TypeList:
+			n, err := ligo.NodeOf(val, ligo.Pos{})
+			if err == nil {
+				fmt.Print(n)
+			}
+		case val.Type == ligo.TypeStruct, val.Type == ligo.TypeRecord:
+			printFields(vm, val)
+		default:
+			if str, ok := ligo.FormatValue(val); ok {
+				fmt.Print(str)
+			} else {
+				fmt.Print("<", val.GetTypeString(), ">")
+			}
 		}
 	}
 	return ligo.Variable{Type: ligo.TypeNil, Value: nil}
 }
 
+// printFields function prints the members of a struct (or record) sorted by
+// their names, the type name being printed before those of a record
+func printFields(vm *ligo.VM, val ligo.Variable) {
+	fields, _ := ligo.StructFields(val)
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if val.Type == ligo.TypeRecord {
+		fmt.Print(val.GetTypeString())
+	}
+	fmt.Print("{")
+	for _, key := range keys {
+		fmt.Print(key, ":")
+		vmPrint(vm, fields[key])
+		fmt.Print(";")
+	}
+	fmt.Print("}")
+}
+
 func vmPrintln(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	vmPrint(vm, a...)
 	fmt.Println("")
 	return ligo.Variable{Type: ligo.TypeNil, Value: nil}
 }
 
+// vmThrow throws an exception. It is called as (throw MESSAGE) or
+// (throw KIND MESSAGE) or (throw KIND MESSAGE PAYLOAD)
 func vmThrow(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
-	if len(a) != 1 {
-		return vm.Throw("Cannot accept more that 1 variable")
+	switch len(a) {
+	case 1:
+		return vm.Throw(fmt.Sprint(a[0].Value))
+	case 2, 3:
+		if a[0].Type != ligo.TypeString {
+			return vm.Throw("throw : expected the kind of the exception as a string, got " + a[0].GetTypeString())
+		}
+		payload := ligo.Variable{Type: ligo.TypeNil, Value: nil}
+		if len(a) == 3 {
+			payload = a[2]
+		}
+		return vm.ThrowException(a[0].Value.(string), fmt.Sprint(a[1].Value), payload)
 	}
-	return vm.Throw(fmt.Sprint(a[0].Value))
+	return vm.Throw(fmt.Sprintf("throw : expected 1 to 3 arguments, got %d", len(a)))
 }
 
 func vmAdd(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
@@ -695,7 +726,12 @@ func vmSleep(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	if a[0].Type != ligo.TypeInt {
 		return vm.Throw("sleep expects only integers")
 	}
-	time.Sleep(time.Duration(a[0].Value.(int64)) * time.Millisecond)
+	timer := time.NewTimer(time.Duration(a[0].Value.(int64)) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-vm.Context().Done():
+	}
 	return ligo.Variable{Type: ligo.TypeNil, Value: nil}
 }
 
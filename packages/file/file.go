@@ -8,6 +8,17 @@ import (
 	"github.com/aki237/ligo/pkg/ligo"
 )
 
+// fileType is the type of the file handlers
+var fileType = ligo.RegisterType(ligo.TypeInfo{
+	Name: "file",
+	Format: func(value interface{}) string {
+		return "<file " + value.(*os.File).Name() + ">"
+	},
+	Close: func(value interface{}) error {
+		return value.(*os.File).Close()
+	},
+})
+
 // PluginInit function is the plugin initializer for the file package
 func PluginInit(vm *ligo.VM) {
 	vm.Funcs["open"] = vmFileOpen   // (open "filename.txt" "rw") => file handler   | panics
@@ -21,7 +32,7 @@ func vmFileSeek(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	if len(a) != 3 {
 		return vm.Throw("file-seek : expected 3 arguments exactly")
 	}
-	if a[0].Type != 0x10080 {
+	if a[0].Type != fileType {
 		return vm.Throw("file-seek : not a valid file handler")
 	}
 
@@ -48,12 +59,11 @@ func vmFileClose(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	if len(a) != 1 {
 		return vm.Throw("file-close : expected 1 arguments exactly")
 	}
-	if a[0].Type != 0x10080 {
+	if a[0].Type != fileType {
 		return vm.Throw("file-close : not a valid file handler")
 	}
 
-	fh := a[0].Value.(*os.File)
-	err := fh.Close()
+	err := ligo.Close(a[0])
 	if err != nil {
 		return ligo.Variable{Type: ligo.TypeErr, Value: err}
 	}
@@ -102,14 +112,14 @@ func vmFileOpen(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 		return vm.Throw("file-open : unrecogonized mode \"" + mode + "\"")
 	}
 
-	return ligo.Variable{Type: 0x10080, Value: fl}
+	return ligo.NewValue(fileType, fl)
 }
 
 func vmFileRead(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	if len(a) != 2 {
 		return vm.Throw("file-read : expected 2 arguments exactly")
 	}
-	if a[0].Type != 0x10080 {
+	if a[0].Type != fileType {
 		return vm.Throw("file-read : not a valid file handler")
 	}
 
@@ -134,7 +144,7 @@ func vmFileWrite(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 	if len(a) != 2 {
 		return vm.Throw("file-write : expected 2 arguments exactly")
 	}
-	if a[0].Type != 0x10080 {
+	if a[0].Type != fileType {
 		return vm.Throw("file-write : not a valid file handler")
 	}
 
@@ -54,7 +54,7 @@ func vmStringFromArray(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 			if val.Value.(int64) <= 0 {
 				return vm.Throw(fmt.Sprintf("string-fromArray : the array can only contain positive integers, got %d", val.Value.(int64)))
 			}
-			ret = string(val.Value.(int64))
+			ret += string(rune(val.Value.(int64)))
 		case ligo.TypeString:
 			ret += val.Value.(string)
 		default:
@@ -19,7 +19,12 @@ func vmURLGet(vm *ligo.VM, a ...ligo.Variable) ligo.Variable {
 
 	url := a[0].Value.(string)
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(vm.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return ligo.Variable{Type: ligo.TypeErr, Value: err}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 
 	if err != nil {
 		return ligo.Variable{Type: ligo.TypeErr, Value: err}
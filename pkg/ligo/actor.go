@@ -0,0 +1,317 @@
+package ligo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Actor is a VM isolated from the others, evaluating an expression in it's own
+// goroutine. The actors don't share any variable, they communicate by sending
+// messages to the mailbox of each other.
+type Actor struct {
+	// task is the evaluation of the actor, nil for the VM not started by spawn-vm
+	task    *Task
+	mailbox *Mailbox
+	pc      *ProcessCommon
+}
+
+// Task method returns the task of the evaluation of the actor. It is nil if the
+// actor is the VM not spawned by spawn-vm (like the one running the script).
+func (a *Actor) Task() *Task {
+	return a.task
+}
+
+// Mailbox is the queue of the messages sent to an actor. Sending a message never
+// waits, the messages are queued till the actor receives them.
+type Mailbox struct {
+	mu       sync.Mutex
+	messages []Variable
+	// ready has a value when there are messages to be received
+	ready chan struct{}
+}
+
+// newMailbox function returns an empty mailbox
+func newMailbox() *Mailbox {
+	return &Mailbox{ready: make(chan struct{}, 1)}
+}
+
+// put method is used to add the message to the end of the queue
+func (m *Mailbox) put(v Variable) {
+	m.mu.Lock()
+	m.messages = append(m.messages, v)
+	m.mu.Unlock()
+	m.signal()
+}
+
+// signal method is used to wake up a receiver waiting for a message
+func (m *Mailbox) signal() {
+	select {
+	case m.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take method is used to remove the first message from the queue. ok is false
+// if the queue is empty.
+func (m *Mailbox) take() (v Variable, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.messages) == 0 {
+		return ligoNil, false
+	}
+	v = m.messages[0]
+	m.messages[0] = ligoNil
+	m.messages = m.messages[1:]
+	if len(m.messages) > 0 {
+		m.signal()
+	}
+	return v, true
+}
+
+// self method returns the actor of the VM. The VM not spawned by spawn-vm gets
+// an actor (with a mailbox) the first time it is needed.
+func (vm *VM) self() *Actor {
+	vm.pc.Lock()
+	defer vm.pc.Unlock()
+	if vm.pc.self == nil {
+		vm.pc.self = &Actor{mailbox: newMailbox(), pc: vm.pc}
+	}
+	return vm.pc.self
+}
+
+// isolator is used to make a deep copy of the values and the scopes of a VM for
+// another VM, so that the two don't share anything which can be changed. The
+// scopes and the values copied are remembered, so that what is shared in the
+// original is shared in the copy too. The scopes are known by their lock, which
+// is the same for the copies of a VM evaluating with another state.
+type isolator struct {
+	pc     *ProcessCommon
+	state  *evalState
+	scopes map[*sync.RWMutex]*VM
+	seen   map[interface{}]interface{}
+}
+
+// newIsolator function returns an isolator making the copies for the VM having
+// the passed process control and evaluation state
+func newIsolator(pc *ProcessCommon, state *evalState) *isolator {
+	return &isolator{
+		pc:     pc,
+		state:  state,
+		scopes: make(map[*sync.RWMutex]*VM),
+		seen:   make(map[interface{}]interface{}),
+	}
+}
+
+// scope method returns the copy of the scope along with it's parent scopes and
+// namespaces. The in-built functions are shared, as they are go functions.
+func (iso *isolator) scope(vm *VM) *VM {
+	if vm == nil {
+		return nil
+	}
+	if nvm, ok := iso.scopes[vm.mu]; ok {
+		return nvm
+	}
+	nvm := NewVM()
+	nvm.pc, nvm.state = iso.pc, iso.state
+	iso.scopes[vm.mu] = nvm
+
+	vm.mu.RLock()
+	vars := make(map[string]Variable, len(vm.Vars))
+	for key, value := range vm.Vars {
+		vars[key] = value
+	}
+	lfuncs := make(map[string]Defined, len(vm.LFuncs))
+	for key, value := range vm.LFuncs {
+		lfuncs[key] = value
+	}
+	namespaces := make(map[string]*VM, len(vm.namespaces))
+	for key, value := range vm.namespaces {
+		namespaces[key] = value
+	}
+	for key, value := range vm.Funcs {
+		nvm.Funcs[key] = value
+	}
+	vm.mu.RUnlock()
+
+	nvm.parent = iso.scope(vm.parent)
+	for key, value := range vars {
+		nvm.Vars[key] = iso.value(value)
+	}
+	for key, value := range lfuncs {
+		nvm.LFuncs[key] = iso.defined(value)
+	}
+	for key, value := range namespaces {
+		nvm.namespaces[key] = iso.scope(value)
+	}
+	if vm.view != nil {
+		nvm.view = &moduleView{scope: iso.scope(vm.view.scope), names: vm.view.names}
+	}
+	return nvm
+}
+
+// defined method returns the copy of the defined function, with a copy of the
+// scope it was defined in
+func (iso *isolator) defined(fn Defined) Defined {
+	fn.env = iso.scope(fn.env)
+	return fn
+}
+
+// value method returns a deep copy of the passed value. The tasks, channels and
+// actors are shared, as they are meant for the communication between the VMs.
+// So are the values of the types registered by the go packages.
+func (iso *isolator) value(v Variable) Variable {
+	switch val := v.Value.(type) {
+	case []Variable:
+		items := make([]Variable, len(val))
+		for i, item := range val {
+			items[i] = iso.value(item)
+		}
+		return Variable{Type: v.Type, Value: items}
+	case Map:
+		key := reflect.ValueOf(val).Pointer()
+		if m, ok := iso.seen[key]; ok {
+			return Variable{Type: v.Type, Value: m}
+		}
+		m := make(Map, len(val))
+		iso.seen[key] = m
+		for k, item := range val {
+			m[iso.value(k)] = iso.value(item)
+		}
+		return Variable{Type: v.Type, Value: m}
+	case map[string]Variable:
+		key := reflect.ValueOf(val).Pointer()
+		if m, ok := iso.seen[key]; ok {
+			return Variable{Type: v.Type, Value: m}
+		}
+		m := copyFields(val)
+		iso.seen[key] = m
+		for k, item := range m {
+			m[k] = iso.value(item)
+		}
+		return Variable{Type: v.Type, Value: m}
+	case *Record:
+		if r, ok := iso.seen[val]; ok {
+			return Variable{Type: v.Type, Value: r}
+		}
+		r := &Record{Type: val.Type, Fields: copyFields(val.Fields)}
+		iso.seen[val] = r
+		for k, item := range r.Fields {
+			r.Fields[k] = iso.value(item)
+		}
+		return Variable{Type: v.Type, Value: r}
+	case *Exception:
+		e := *val
+		e.Payload = iso.value(val.Payload)
+		return Variable{Type: v.Type, Value: &e}
+	case Defined:
+		return Variable{Type: v.Type, Value: iso.defined(val)}
+	case Macro:
+		return Variable{Type: v.Type, Value: Macro{fn: iso.defined(val.fn)}}
+	}
+	return v
+}
+
+// spawnVM method is used to run the spawn-vm construct. The expression is
+// evaluated in a new goroutine, by a VM having a copy of all the variables and
+// functions visible in the current scope. The actor of the VM is returned, which
+// can be sent messages and joined like a task. If the evaluation fails, the
+// actor which spawned it is sent a struct with the members Actor and Exception.
+// The work done by the actor is charged to the evaluation which spawned it, like
+// that of a forked task.
+func (vm *VM) spawnVM(tkns []Node) (Variable, error) {
+	if len(tkns) != 2 {
+		return ligoNil, Error("spawn-vm : expected one expression, got " + fmt.Sprint(len(tkns)-1) + " arguments")
+	}
+	vm.pc.Lock()
+	pc := &ProcessCommon{
+		budget:      vm.pc.budget,
+		memoryLimit: vm.pc.memoryLimit,
+		maxDepth:    vm.pc.maxDepth,
+		loader:      vm.pc.loader,
+	}
+	vm.pc.Unlock()
+	ctx, cancel := context.WithCancel(vm.state.ctx)
+	st := newEvalState(ctx)
+	st.meter, st.active = vm.state.meter, 1
+	actor := &Actor{mailbox: newMailbox(), pc: pc}
+	pc.self = actor
+	nvm := newIsolator(pc, st).scope(vm)
+
+	supervisor := vm.self()
+	actor.task = runTask(nvm, tkns[1], cancel, func(t *Task) {
+		if t.err == nil {
+			return
+		}
+		_, err := nvm.taskResult(t)
+		e, ok := err.(*Exception)
+		if !ok {
+			e = nvm.newException(DefaultExceptionKind, err.Error(), ligoNil)
+		}
+		iso := newIsolator(supervisor.pc, newEvalState(context.Background()))
+		supervisor.mailbox.put(Variable{Type: TypeStruct, Value: map[string]Variable{
+			"Actor":     {Type: TypeActor, Value: actor},
+			"Exception": iso.value(Variable{Type: TypeException, Value: e}),
+		}})
+	})
+	return Variable{Type: TypeActor, Value: actor}, nil
+}
+
+// send method is the send function. A deep copy of the message is added to the
+// mailbox of the actor, so that the actors don't share the values. It doesn't
+// wait for the actor to receive the message.
+func (vm *VM) send(vars ...Variable) Variable {
+	if len(vars) != 2 {
+		return vm.Throw("send : expected (send ACTOR MESSAGE)")
+	}
+	actor, ok := vars[0].Value.(*Actor)
+	if vars[0].Type != TypeActor || !ok {
+		return vm.Throw("send : expected an actor, got " + vars[0].GetTypeString())
+	}
+	msg := newIsolator(actor.pc, newEvalState(context.Background())).value(vars[1])
+	actor.mailbox.put(msg)
+	return ligoNil
+}
+
+// receive method is the receive function. It waits for a message to the actor
+// of the VM and returns it. A timeout in milliseconds can be passed, nil is
+// returned if no message is received in that time.
+func (vm *VM) receive(vars ...Variable) Variable {
+	if len(vars) > 1 {
+		return vm.Throw("receive : expected (receive [TIMEOUT])")
+	}
+	var timeout <-chan time.Time
+	if len(vars) == 1 {
+		ms, ok := vars[0].Value.(int64)
+		if vars[0].Type != TypeInt || !ok {
+			return vm.Throw("receive : timeout should be an integer, got " + vars[0].GetTypeString())
+		}
+		timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	mailbox := vm.self().mailbox
+	for {
+		if msg, ok := mailbox.take(); ok {
+			return msg
+		}
+		select {
+		case <-mailbox.ready:
+		case <-timeout:
+			return ligoNil
+		case <-vm.state.done:
+			return vm.raise(vm.state.interrupted())
+		}
+	}
+}
+
+// selfActor method is the self function, which returns the actor of the VM. It
+// can be sent to other actors, so that they can reply.
+func (vm *VM) selfActor(vars ...Variable) Variable {
+	if len(vars) != 0 {
+		return vm.Throw("self : expected no arguments")
+	}
+	return Variable{Type: TypeActor, Value: vm.self()}
+}
@@ -0,0 +1,111 @@
+package ligo
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// Node is an element of the syntax tree generated from a ligo source.
+// The source is parsed only once and the VM evaluates the nodes directly.
+type Node interface {
+	String() string
+	Pos() Pos
+}
+
+// List node is a parenthesised sub expression like (fn arg1 arg2)
+type List struct {
+	Nodes []Node
+	pos   Pos
+	// expansion holds the *expansion of the list, if it is a macro call
+	expansion atomic.Value
+}
+
+// Array node is a square bracketed array literal like [1 2 3]
+type Array struct {
+	Nodes []Node
+	pos   Pos
+}
+
+// Symbol node is a name that refers to a variable or a function in the VM
+type Symbol struct {
+	Name string
+	pos  Pos
+}
+
+// Literal node is a constant value (int, float, string or bool) found in the source
+type Literal struct {
+	Value Variable
+	raw   string
+	pos   Pos
+}
+
+// Closure node is the parameter list of a function construct like |a b ...rest|
+type Closure struct {
+	Params []string
+	pos    Pos
+}
+
+// String method returns the source form of the list
+func (l *List) String() string {
+	return "(" + joinNodes(l.Nodes) + ")"
+}
+
+// String method returns the source form of the array
+func (a *Array) String() string {
+	return "[" + joinNodes(a.Nodes) + "]"
+}
+
+// String method returns the name of the symbol
+func (s *Symbol) String() string {
+	return s.Name
+}
+
+// String method returns the literal as it was written in the source
+func (l *Literal) String() string {
+	return l.raw
+}
+
+// String method returns the source form of the closure
+func (c *Closure) String() string {
+	return "|" + strings.Join(c.Params, " ") + "|"
+}
+
+// Pos method returns the position of the list in the source
+func (l *List) Pos() Pos {
+	return l.pos
+}
+
+// Pos method returns the position of the array in the source
+func (a *Array) Pos() Pos {
+	return a.pos
+}
+
+// Pos method returns the position of the symbol in the source
+func (s *Symbol) Pos() Pos {
+	return s.pos
+}
+
+// Pos method returns the position of the literal in the source
+func (l *Literal) Pos() Pos {
+	return l.pos
+}
+
+// Pos method returns the position of the closure in the source
+func (c *Closure) Pos() Pos {
+	return c.pos
+}
+
+// joinNodes is used to join the source forms of the passed nodes with a space
+func joinNodes(nodes []Node) string {
+	strs := make([]string, 0, len(nodes))
+	for _, val := range nodes {
+		strs = append(strs, val.String())
+	}
+	return strings.Join(strs, " ")
+}
+
+// isSymbol is used to check whether the passed node is a symbol of the given name
+func isSymbol(n Node, name string) bool {
+	s, ok := n.(*Symbol)
+	return ok && s.Name == name
+}
@@ -0,0 +1,132 @@
+package ligo
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// Budget is the limit on the work done by a single evaluation of the VM.
+// Steps is the number of nodes evaluated (a loop iteration is a step too) and
+// Calls is the number of function calls. A zero field means no limit.
+type Budget struct {
+	Steps int64
+	Calls int64
+}
+
+// Usage is the work done by an evaluation of the VM. Bytes is the approximate
+// size of the values allocated.
+type Usage struct {
+	Steps int64
+	Calls int64
+	Bytes int64
+}
+
+// meter counts the work done by an evaluation. It is shared by the forked
+// evaluations, so that the work done by them is charged to the same budget.
+type meter struct {
+	steps int64
+	calls int64
+	bytes int64
+}
+
+// usage method returns the work counted so far
+func (m *meter) usage() Usage {
+	return Usage{
+		Steps: atomic.LoadInt64(&m.steps),
+		Calls: atomic.LoadInt64(&m.calls),
+		Bytes: atomic.LoadInt64(&m.bytes),
+	}
+}
+
+// budgetError is the error returned when an evaluation exceeds it's budget.
+// It can't be caught by the try construct.
+type budgetError struct {
+	what  string
+	limit int64
+}
+
+// Error method implements the error interface for the type budgetError
+func (be *budgetError) Error() string {
+	return fmt.Sprintf("%s : more than %d %s", ErrBudgetExceeded, be.limit, be.what)
+}
+
+// Is method reports that the error is an ErrBudgetExceeded
+func (be *budgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// SetBudget method is used to set the limit on the work done by each evaluation
+// (Eval, EvalContext, LoadReader or RunDefined called from go) of the VM.
+// It is read by the evaluations without a lock, so it should be set before the
+// VM is shared by the goroutines.
+func (vm *VM) SetBudget(b Budget) {
+	vm.pc.budget = b
+}
+
+// LastUsage method returns the work done by the last evaluation finished in the VM.
+// When evaluations run concurrently it can be the one of any of them, EvalUsage
+// returns the work done by a single evaluation.
+func (vm *VM) LastUsage() Usage {
+	vm.pc.Lock()
+	defer vm.pc.Unlock()
+	return vm.pc.last
+}
+
+// EvalUsage method is used to evaluate the passed string like EvalContext, and
+// returns the work done by the evaluation too.
+func (vm *VM) EvalUsage(ctx context.Context, stmt string) (Variable, Usage, error) {
+	vm = vm.withState(newEvalState(ctx))
+	defer vm.enter()()
+	v, err := vm.Eval(stmt)
+	return v, vm.state.meter.usage(), err
+}
+
+// TotalUsage method returns the work done by all the evaluations finished in the VM
+func (vm *VM) TotalUsage() Usage {
+	vm.pc.Lock()
+	defer vm.pc.Unlock()
+	return vm.pc.total
+}
+
+// enter method is used to mark the start of an evaluation. The work is counted
+// afresh if it is not nested in another evaluation. The returned function marks
+// the end of it and records the work done in the VM.
+func (vm *VM) enter() func() {
+	st := vm.state
+	if st.active == 0 {
+		st.meter = &meter{}
+	}
+	st.active++
+	return func() {
+		st.active--
+		if st.active != 0 {
+			return
+		}
+		u := st.meter.usage()
+		vm.pc.Lock()
+		vm.pc.last = u
+		vm.pc.total.Steps += u.Steps
+		vm.pc.total.Calls += u.Calls
+		vm.pc.total.Bytes += u.Bytes
+		vm.pc.Unlock()
+	}
+}
+
+// step method is used to count an evaluation step against the budget
+func (vm *VM) step() error {
+	n := atomic.AddInt64(&vm.state.meter.steps, 1)
+	if limit := vm.pc.budget.Steps; limit > 0 && n > limit {
+		return &budgetError{what: "steps", limit: limit}
+	}
+	return nil
+}
+
+// charge method is used to count a function call against the budget
+func (vm *VM) charge() error {
+	n := atomic.AddInt64(&vm.state.meter.calls, 1)
+	if limit := vm.pc.budget.Calls; limit > 0 && n > limit {
+		return &budgetError{what: "calls", limit: limit}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package ligo
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Channel is a go channel of ligo values, used by the forked tasks to
+// communicate with each other.
+type Channel struct {
+	ch chan Variable
+}
+
+// NewChannel function returns a channel variable buffering the passed number of
+// values. A channel with no buffer blocks the sender till the value is received.
+func NewChannel(capacity int) Variable {
+	return Variable{Type: TypeChannel, Value: &Channel{ch: make(chan Variable, capacity)}}
+}
+
+// Chan method returns the go channel of the channel, so that go packages can
+// send and receive the values
+func (c *Channel) Chan() chan Variable {
+	return c.ch
+}
+
+// send method is used to send the value on the channel. A send on a closed
+// channel returns an error instead of the panic of go.
+func (c *Channel) send(form string, v Variable, done <-chan struct{}) (sent bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sent, err = false, Error(form+" : send on a closed channel")
+		}
+	}()
+	select {
+	case c.ch <- v:
+		return true, nil
+	case <-done:
+		return false, nil
+	}
+}
+
+// close method is used to close the channel. Closing a closed channel returns
+// an error.
+func (c *Channel) close() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Error("chan-close : channel is already closed")
+		}
+	}()
+	close(c.ch)
+	return nil
+}
+
+// channelArg function is used to check that the passed value is a channel
+func channelArg(form string, v Variable) (*Channel, error) {
+	c, ok := v.Value.(*Channel)
+	if v.Type != TypeChannel || !ok {
+		return nil, Error(form + " : expected a channel, got " + v.GetTypeString())
+	}
+	return c, nil
+}
+
+// channelArgs function is used to check the arguments of the channel functions.
+// The first argument should be a channel, n is the number of arguments expected.
+func channelArgs(form string, vars []Variable, n int) (*Channel, []Variable, error) {
+	if len(vars) != n {
+		return nil, nil, Error(fmt.Sprintf("%s : expected %d arguments, got %d", form, n, len(vars)))
+	}
+	c, err := channelArg(form, vars[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, vars[1:], nil
+}
+
+// recv method is used to receive a value from the channel. ok is false if the
+// channel is closed. The wait is interrupted if the current evaluation is cancelled.
+func (vm *VM) recv(c *Channel) (v Variable, ok bool, err error) {
+	select {
+	case v, ok = <-c.ch:
+		if !ok {
+			return ligoNil, false, nil
+		}
+		return v, true, nil
+	case <-vm.state.done:
+		return ligoNil, false, vm.state.interrupted()
+	}
+}
+
+// chanNew method is the chan-new function, which returns a new channel. The
+// capacity of the channel (0 by default) can be passed.
+func (vm *VM) chanNew(vars ...Variable) Variable {
+	if len(vars) > 1 {
+		return vm.Throw("chan-new : expected (chan-new [CAPACITY])")
+	}
+	capacity := int64(0)
+	if len(vars) == 1 {
+		c, ok := vars[0].Value.(int64)
+		if vars[0].Type != TypeInt || !ok || c < 0 {
+			return vm.Throw("chan-new : capacity should be a positive integer, got " + vars[0].GetTypeString())
+		}
+		capacity = c
+	}
+	return NewChannel(int(capacity))
+}
+
+// chanSend method is the chan-send function. It waits till the value is sent on
+// the channel.
+func (vm *VM) chanSend(vars ...Variable) Variable {
+	c, args, err := channelArgs("chan-send", vars, 2)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	sent, err := c.send("chan-send", args[0], vm.state.done)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	if !sent {
+		return vm.raise(vm.state.interrupted())
+	}
+	return ligoNil
+}
+
+// chanRecv method is the chan-recv function. It waits for a value on the channel
+// and returns it. () (nil) is returned once the channel is closed.
+func (vm *VM) chanRecv(vars ...Variable) Variable {
+	c, _, err := channelArgs("chan-recv", vars, 1)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	v, _, err := vm.recv(c)
+	if err != nil {
+		return vm.raise(err)
+	}
+	return v
+}
+
+// chanClose method is the chan-close function. The values already sent can
+// still be received, the receivers get () (nil) after that.
+func (vm *VM) chanClose(vars ...Variable) Variable {
+	c, _, err := channelArgs("chan-close", vars, 1)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	if err := c.close(); err != nil {
+		return vm.Throw(err.Error())
+	}
+	return ligoNil
+}
+
+// selectClause is a parsed clause of the select construct
+type selectClause struct {
+	body []Node
+	// name is the variable bound to the received value, if any
+	name string
+}
+
+// selectEval method is used to run the select construct. It waits for the first
+// of the clauses to be ready and runs it's body. The clauses are
+//
+//	((chan-recv CH [NAME]) BODY...) run when a value is received, bound to NAME
+//	((chan-send CH VALUE) BODY...)  run when the value is sent
+//	((timeout MS) BODY...)          run if no other clause is ready in MS milliseconds
+//	(default BODY...)               run if no other clause is ready right away
+//
+// The channels and the values of the clauses are evaluated before waiting.
+func (vm *VM) selectEval(tkns []Node) (Variable, error) {
+	clauses := make([]selectClause, 0, len(tkns))
+	cases := make([]reflect.SelectCase, 0, len(tkns))
+	var defaultBody []Node
+	hasDefault, hasTimeout := false, false
+	for _, n := range tkns[1:] {
+		clause, ok := n.(*List)
+		if !ok || len(clause.Nodes) == 0 {
+			return ligoNil, Error("select : expected a clause like ((chan-recv CH NAME) BODY...), got " + n.String())
+		}
+		if isSymbol(clause.Nodes[0], "default") {
+			if hasDefault {
+				return ligoNil, Error("select : more than one default clause")
+			}
+			hasDefault, defaultBody = true, clause.Nodes[1:]
+			continue
+		}
+		op, ok := clause.Nodes[0].(*List)
+		if !ok || len(op.Nodes) == 0 {
+			return ligoNil, Error("select : expected a channel operation, timeout or default, got " + clause.Nodes[0].String())
+		}
+		sc := selectClause{body: clause.Nodes[1:]}
+		switch {
+		case isSymbol(op.Nodes[0], "chan-recv"):
+			if len(op.Nodes) < 2 || len(op.Nodes) > 3 {
+				return ligoNil, Error("select : expected (chan-recv CH [NAME]), got " + op.String())
+			}
+			if len(op.Nodes) == 3 {
+				name, ok := op.Nodes[2].(*Symbol)
+				if !ok || !rVariable.MatchString(name.Name) {
+					return ligoNil, Error("select : invalid variable name " + op.Nodes[2].String())
+				}
+				sc.name = name.Name
+			}
+			c, err := vm.selectChannel(op.Nodes[1])
+			if err != nil {
+				return ligoNil, err
+			}
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.ch)})
+		case isSymbol(op.Nodes[0], "chan-send"):
+			if len(op.Nodes) != 3 {
+				return ligoNil, Error("select : expected (chan-send CH VALUE), got " + op.String())
+			}
+			c, err := vm.selectChannel(op.Nodes[1])
+			if err != nil {
+				return ligoNil, err
+			}
+			v, err := vm.eval(op.Nodes[2])
+			if err != nil {
+				return ligoNil, err
+			}
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(c.ch), Send: reflect.ValueOf(v)})
+		case isSymbol(op.Nodes[0], "timeout"):
+			if hasTimeout || len(op.Nodes) != 2 {
+				return ligoNil, Error("select : expected a single (timeout MS) clause")
+			}
+			hasTimeout = true
+			v, err := vm.eval(op.Nodes[1])
+			if err != nil {
+				return ligoNil, err
+			}
+			ms, ok := v.Value.(int64)
+			if v.Type != TypeInt || !ok {
+				return ligoNil, Error("select : timeout should be an integer, got " + v.GetTypeString())
+			}
+			timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+			defer timer.Stop()
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+		default:
+			return ligoNil, Error("select : expected a channel operation, timeout or default, got " + op.String())
+		}
+		clauses = append(clauses, sc)
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(vm.state.done)})
+	if hasDefault {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+	chosen, recv, ok, err := selectCases(cases)
+	if err != nil {
+		return ligoNil, err
+	}
+	switch {
+	case chosen == len(clauses):
+		return ligoNil, vm.state.interrupted()
+	case chosen > len(clauses):
+		return vm.tailBody(defaultBody)
+	}
+	sc := clauses[chosen]
+	if sc.name == "" {
+		return vm.tailBody(sc.body)
+	}
+	scope := vm.NewScope()
+	value := ligoNil
+	if ok {
+		value = recv.Interface().(Variable)
+	}
+	scope.bind(sc.name, value)
+	return scope.tailBody(sc.body)
+}
+
+// selectChannel method is used to evaluate the channel of a select clause
+func (vm *VM) selectChannel(n Node) (*Channel, error) {
+	v, err := vm.eval(n)
+	if err != nil {
+		return nil, err
+	}
+	return channelArg("select", v)
+}
+
+// selectCases function runs the select, returning an error instead of the panic
+// of go if a value is sent on a closed channel.
+func selectCases(cases []reflect.SelectCase) (chosen int, recv reflect.Value, ok bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Error("select : send on a closed channel")
+		}
+	}()
+	chosen, recv, ok = reflect.Select(cases)
+	return chosen, recv, ok, nil
+}
@@ -0,0 +1,190 @@
+package ligo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The tests of this file are meant to be run with -race too
+
+// prepare function returns a test VM with the definitions shared by the
+// goroutines of the tests
+func prepare(t *testing.T) *VM {
+	t.Helper()
+	vm := newTestVM(t)
+	_, err := vm.Eval(`
+		(fn count |n| (progn (var i 0) (loop (< i n) (set i (+ i 1))) i))
+		(var shared 0)
+		(namespace ns0 (var x 0))
+		(namespace ns1 (var x 0))
+		(namespace ns2 (var x 0))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+// parallel function runs the passed function from n goroutines and fails the
+// test with the errors returned by them
+func parallel(t *testing.T, n int, f func(g int) error) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for g := 0; g < n; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			if err := f(g); err != nil {
+				errs <- fmt.Errorf("goroutine %d : %v", g, err)
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConcurrentEval(t *testing.T) {
+	vm := prepare(t)
+	parallel(t, 16, func(g int) error {
+		for i := 0; i < 20; i++ {
+			v, err := vm.Eval(fmt.Sprintf(`(progn
+				(var local%dx%d %d)
+				(set shared (+ shared 1))
+				(namespace ns%d (set x (+ x 1)))
+				(fn double%d |n| (+ n n))
+				(let ((a 1)) (count (+ a (double%d 10)))))`, g, i, i, g%3, g, g))
+			if err != nil {
+				return err
+			}
+			if v.Value.(int64) != 21 {
+				return fmt.Errorf("got %v, want 21", v)
+			}
+		}
+		return nil
+	})
+	// the updates of shared can be lost, only the absence of races is checked
+	for g := 0; g < 16; g++ {
+		if _, err := vm.Eval(fmt.Sprintf("(double%d 1)", g)); err != nil {
+			t.Errorf("function defined by goroutine %d : %v", g, err)
+		}
+	}
+}
+
+func TestConcurrentEvalContext(t *testing.T) {
+	vm := prepare(t)
+	parallel(t, 16, func(g int) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if g%2 == 0 {
+			v, err := vm.EvalContext(ctx, `(count 100)`)
+			if err != nil {
+				return err
+			}
+			if v.Value.(int64) != 100 {
+				return fmt.Errorf("got %v, want 100", v)
+			}
+			return nil
+		}
+		// the others are cancelled while running an endless loop
+		time.AfterFunc(20*time.Millisecond, cancel)
+		_, err := vm.EvalContext(ctx, `(loop true (set shared (+ shared 1)))`)
+		if !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("expected context.Canceled, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestConcurrentFork(t *testing.T) {
+	vm := prepare(t)
+	parallel(t, 8, func(g int) error {
+		for i := 0; i < 10; i++ {
+			v, err := vm.Eval(`(let ((tasks [(fork (count 10)) (fork (set shared (+ shared 1))) (fork (namespace ns1 (set x (+ x 1))))]))
+				(join-all tasks)
+				(join (fork (count 20))))`)
+			if err != nil {
+				return err
+			}
+			if v.Value.(int64) != 20 {
+				return fmt.Errorf("got %v, want 20", v)
+			}
+		}
+		return nil
+	})
+}
+
+func TestConcurrentHost(t *testing.T) {
+	vm := prepare(t)
+	parallel(t, 8, func(g int) error {
+		for i := 0; i < 20; i++ {
+			if _, err := vm.Eval(`(set shared (+ shared 1))`); err != nil {
+				return err
+			}
+			vm.Define(fmt.Sprintf("def%d", g), Variable{Type: TypeInt, Value: int64(i)})
+			vm.LoadPlugin(func(p *VM) { p.Funcs[fmt.Sprintf("add%d", g)] = testAdd })
+			vm.CreateNamespace(fmt.Sprintf("host%d", g))
+			vm.Stats()
+		}
+		return nil
+	})
+	v, err := vm.Eval(`(add3 def3 1)`)
+	if err != nil || v.Value.(int64) != 20 {
+		t.Fatalf("got %v, %v, want 20", v, err)
+	}
+}
+
+func TestConcurrentCallback(t *testing.T) {
+	vm := prepare(t)
+	// map-async calls the passed function with every item of the array, each
+	// from a goroutine of it's own
+	vm.Funcs["map-async"] = func(vm *VM, a ...Variable) Variable {
+		fn := a[0].Value.(Defined)
+		items := a[1].Value.([]Variable)
+		out := make([]Variable, len(items))
+		errs := make([]error, len(items))
+		var wg sync.WaitGroup
+		for i, item := range items {
+			wg.Add(1)
+			go func(dvm *VM, i int, item Variable) {
+				defer wg.Done()
+				out[i], errs[i] = dvm.RunDefined(fn, []Variable{item})
+			}(vm.Detach(), i, item)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return vm.Throw(err.Error())
+			}
+		}
+		return Variable{Type: TypeArray, Value: out}
+	}
+	counted, err := vm.Eval(`[1 2 3 4 5 6 7 8]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel(t, 4, func(g int) error {
+		v, err := vm.Eval(`(map-async (lambda |n| (progn (set shared (+ shared 1)) (count n))) [1 2 3 4 5 6 7 8])`)
+		if err != nil {
+			return err
+		}
+		if !Equal(v, counted) {
+			return fmt.Errorf("got %v, want %v", v, counted)
+		}
+		return nil
+	})
+
+	// the detached evaluations are interrupted with the evaluation calling them
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = vm.EvalContext(ctx, `(map-async (lambda |n| (loop true n)) [1 2])`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to interrupt the callbacks, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package ligo
+
+// condition method is used to evaluate the condition of a construct, which
+// should be a boolean.
+func (vm *VM) condition(n Node) (bool, error) {
+	result, err := vm.eval(n)
+	if err != nil {
+		return false, err
+	}
+	if result.Type != TypeBool {
+		return false, Error("Expected boolean return from the expression : " + n.String())
+	}
+	return result.Value.(bool), nil
+}
+
+// andEval method is used to run the and construct. The conditions are evaluated
+// one after another till one of them is false, the rest are not evaluated then.
+func (vm *VM) andEval(tkns []Node) (Variable, error) {
+	for _, n := range tkns[1:] {
+		ok, err := vm.condition(n)
+		if err != nil {
+			return ligoNil, err
+		}
+		if !ok {
+			return Variable{Type: TypeBool, Value: false}, nil
+		}
+	}
+	return Variable{Type: TypeBool, Value: true}, nil
+}
+
+// orEval method is used to run the or construct. The conditions are evaluated
+// one after another till one of them is true, the rest are not evaluated then.
+func (vm *VM) orEval(tkns []Node) (Variable, error) {
+	for _, n := range tkns[1:] {
+		ok, err := vm.condition(n)
+		if err != nil {
+			return ligoNil, err
+		}
+		if ok {
+			return Variable{Type: TypeBool, Value: true}, nil
+		}
+	}
+	return Variable{Type: TypeBool, Value: false}, nil
+}
+
+// condEval method is used to run the cond construct. The body of the first clause
+// whose condition is true is run, the else clause (if any) is run when none of
+// them are. A clause without a body returns the value of it's condition.
+func (vm *VM) condEval(tkns []Node) (Variable, error) {
+	for i, n := range tkns[1:] {
+		clause, ok := n.(*List)
+		if !ok || len(clause.Nodes) == 0 {
+			return ligoNil, Error("cond : expected a clause like (CONDITION BODY...), got " + n.String())
+		}
+		if isSymbol(clause.Nodes[0], "else") {
+			if i != len(tkns)-2 {
+				return ligoNil, Error("cond : else clause should be placed at last")
+			}
+			return vm.tailBody(clause.Nodes[1:])
+		}
+		ok, err := vm.condition(clause.Nodes[0])
+		if err != nil {
+			return ligoNil, err
+		}
+		if !ok {
+			continue
+		}
+		if len(clause.Nodes) == 1 {
+			return Variable{Type: TypeBool, Value: true}, nil
+		}
+		return vm.tailBody(clause.Nodes[1:])
+	}
+	return ligoNil, nil
+}
+
+// whenEval method is used to run the when construct. The body is run only if the
+// condition is true.
+func (vm *VM) whenEval(tkns []Node) (Variable, error) {
+	return vm.guardedBody(tkns, true)
+}
+
+// unlessEval method is used to run the unless construct. The body is run only if
+// the condition is false.
+func (vm *VM) unlessEval(tkns []Node) (Variable, error) {
+	return vm.guardedBody(tkns, false)
+}
+
+// guardedBody method is used to run the body of the when or unless construct
+// if the condition evaluates to the passed value.
+func (vm *VM) guardedBody(tkns []Node, run bool) (Variable, error) {
+	if len(tkns) < 3 {
+		return ligoNil, Error(tkns[0].String() + " : expected (" + tkns[0].String() + " CONDITION BODY...)")
+	}
+	ok, err := vm.condition(tkns[1])
+	if err != nil {
+		return ligoNil, err
+	}
+	if ok != run {
+		return ligoNil, nil
+	}
+	return vm.tailBody(tkns[2:])
+}
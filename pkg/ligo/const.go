@@ -7,6 +7,8 @@ const (
 	ErrFuncNotFound        Error = "Function not defined in scope"
 	ErrSignalRecieved      Error = "Caught cancellation amidst evaluation"
 	ErrExceptionNotHandled Error = "Exception not handled"
+	ErrDeadlineExceeded    Error = "Evaluation deadline exceeded"
+	ErrBudgetExceeded      Error = "Execution budget exceeded"
 )
 
 // Type is a type to denote the type of Variables in the VM
@@ -14,18 +16,47 @@ type Type int
 
 // Required constants for the variable type
 const (
-	TypeErr    Type = -0x00
-	TypeInt    Type = 0x000
-	TypeFloat  Type = 0x001
-	TypeBool   Type = 0x002
-	TypeString Type = 0x003
-	TypeNil    Type = 0x004
-	TypeIFunc  Type = 0x005
-	TypeDFunc  Type = 0x006
-	TypeExp    Type = 0x007
-	TypeArray  Type = 0x100
-	TypeMap    Type = 0x300
-	TypeStruct Type = 0x400
+	TypeErr        Type = -0x00
+	TypeInt        Type = 0x000
+	TypeFloat      Type = 0x001
+	TypeBool       Type = 0x002
+	TypeString     Type = 0x003
+	TypeNil        Type = 0x004
+	TypeIFunc      Type = 0x005
+	TypeDFunc      Type = 0x006
+	TypeExp        Type = 0x007
+	TypeSymbol     Type = 0x008
+	TypeMacro      Type = 0x009
+	TypeRecordType Type = 0x00a
+	TypeTask       Type = 0x00b
+	TypeChannel    Type = 0x00c
+	TypeActor      Type = 0x00d
+	TypeArray      Type = 0x100
+	TypeList       Type = 0x200
+	TypeMap        Type = 0x300
+	TypeStruct     Type = 0x400
+	TypeException  Type = 0x500
+	TypeRecord     Type = 0x600
+
+	// typeRegistered is the first of the types allocated by RegisterType
+	typeRegistered Type = 0x10000
 )
 
+// Kinds of the exceptions raised by the VM
+const (
+	// DefaultExceptionKind is the kind of the exceptions thrown without a kind
+	DefaultExceptionKind = "error"
+	// PanicExceptionKind is the kind of the exceptions raised for a go panic
+	PanicExceptionKind = "panic"
+	// MemoryExceptionKind is the kind of the exceptions raised when the memory limit is exceeded
+	MemoryExceptionKind = "memory"
+	// StackOverflowExceptionKind is the kind of the exceptions raised when the call depth is exceeded
+	StackOverflowExceptionKind = "stack overflow"
+	// CancelledExceptionKind is the kind of the exceptions raised when a cancelled task is joined
+	CancelledExceptionKind = "cancelled"
+)
+
+// DefaultMaxCallDepth is the maximum depth of the nested function calls for a new VM
+const DefaultMaxCallDepth = 10000
+
 var ligoNil = Variable{TypeNil, nil}
@@ -1,5 +1,9 @@
 package ligo
 
+import (
+	"fmt"
+)
+
 // Error is a type string used to denote errors from the VM
 type Error string
 
@@ -7,3 +11,73 @@ type Error string
 func (le Error) Error() string {
 	return string(le)
 }
+
+// Pos is a struct denoting a position in a ligo source
+type Pos struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String method returns the position in the "file:line:column" format.
+// The file is omitted if the source is not read from a file.
+func (p Pos) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// SourceError is an error returned by the VM along with the position
+// of the node in the source where the error occurred and the call stack
+// at that point.
+type SourceError struct {
+	Pos   Pos
+	Err   error
+	Stack StackTrace
+}
+
+// Error method implements the error interface for the type SourceError
+func (se *SourceError) Error() string {
+	return se.Pos.String() + ": " + se.Err.Error()
+}
+
+// Unwrap method returns the underlying error, so that the errors package can
+// be used to compare with the error constants.
+func (se *SourceError) Unwrap() error {
+	return se.Err
+}
+
+// cancelError is the error returned when the context of an evaluation is done.
+// It matches both the VM error and the context error with errors.Is.
+type cancelError struct {
+	err   Error
+	cause error
+}
+
+// Error method implements the error interface for the type cancelError
+func (ce *cancelError) Error() string {
+	return ce.err.Error()
+}
+
+// Unwrap method returns the context error which caused the interruption
+func (ce *cancelError) Unwrap() error {
+	return ce.cause
+}
+
+// Is method reports whether the target is the VM error of the interruption
+func (ce *cancelError) Is(target error) bool {
+	return target == ce.err
+}
+
+// errorAt method is used to attach the position of the passed node and the
+// current call stack to the error. An error which already has a position
+// (from a deeper node), a jump of return, break or continue or an exception
+// is left as it is.
+func (vm *VM) errorAt(n Node, err error) error {
+	switch err.(type) {
+	case *SourceError, *jump, *Exception:
+		return err
+	}
+	return &SourceError{Pos: n.Pos(), Err: err, Stack: vm.state.trace()}
+}
@@ -0,0 +1,272 @@
+package ligo
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// Exception is the value thrown by the throw function or by the in-built functions
+// using the Throw method. It unwinds the evaluation till a try construct catches it.
+// The position and the call stack at the throw are recorded for the report when it
+// is not caught.
+type Exception struct {
+	Kind    string
+	Message string
+	Payload Variable
+	Pos     Pos
+	Stack   StackTrace
+	GoStack string
+}
+
+// Error method implements the error interface for the type Exception.
+// The kind is shown only if it is not the default one.
+func (e *Exception) Error() string {
+	if e.Kind == DefaultExceptionKind {
+		return e.Message
+	}
+	return e.Kind + " : " + e.Message
+}
+
+// fields method returns the members of the exception accessible from the
+// ligo script like a struct (e:Message, e:Kind and e:Payload)
+func (e *Exception) fields() map[string]Variable {
+	return map[string]Variable{
+		"Kind":    {Type: TypeString, Value: e.Kind},
+		"Message": {Type: TypeString, Value: e.Message},
+		"Payload": e.Payload,
+		"GoStack": {Type: TypeString, Value: e.GoStack},
+	}
+}
+
+// unhandled is the error returned for an exception which is not caught
+// till the top level
+type unhandled struct {
+	e *Exception
+}
+
+// Error method implements the error interface for the type unhandled
+func (u unhandled) Error() string {
+	return string(ErrExceptionNotHandled) + " : " + u.e.Error()
+}
+
+// Unwrap method returns the exception which is not handled
+func (u unhandled) Unwrap() error {
+	return u.e
+}
+
+// Is method reports that the error is an ErrExceptionNotHandled
+func (u unhandled) Is(target error) bool {
+	return target == ErrExceptionNotHandled
+}
+
+// Throw method is used to throw an exception of the default kind in the VM.
+// It is used by the in-built functions, the exception is raised as soon as the
+// function returns.
+func (vm *VM) Throw(exception string) Variable {
+	return vm.ThrowException(DefaultExceptionKind, exception, ligoNil)
+}
+
+// ThrowException method is used to throw an exception of the passed kind with a
+// payload in the VM. The call stack at this point is recorded. Only the first
+// exception thrown in an in-built function call is raised.
+func (vm *VM) ThrowException(kind, message string, payload Variable) Variable {
+	if vm.state.thrown != nil {
+		return ligoNil
+	}
+	vm.state.thrown = vm.newException(kind, message, payload)
+	return ligoNil
+}
+
+// raise method is used by the in-built functions of the language to raise an
+// error which is not thrown by them, like the exception of a task joined or the
+// interruption of the evaluation. It is returned as it is, so the errors which
+// can't be caught stay so. Like ThrowException, only the first one is raised.
+func (vm *VM) raise(err error) Variable {
+	if vm.state.thrown == nil {
+		vm.state.thrown = err
+	}
+	return ligoNil
+}
+
+// newException method returns an exception with the current call stack
+func (vm *VM) newException(kind, message string, payload Variable) *Exception {
+	e := &Exception{Kind: kind, Message: message, Payload: payload, Stack: vm.state.trace()}
+	if len(e.Stack) > 0 {
+		e.Pos = e.Stack[0].Pos
+	}
+	return e
+}
+
+// recoverPanic method is deferred to recover a panic in the evaluation or in an
+// in-built function, and return it as an exception of the kind "panic" with the
+// function name and the go stack. The call stack is unwound to the passed depth.
+// The innermost ligo function is named if fnName is not passed. The exception is
+// at the position of the passed node if any, like the keyword call panicking.
+func (vm *VM) recoverPanic(fnName string, n Node, depth int, v *Variable, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	st := vm.state
+	e := &Exception{Kind: PanicExceptionKind, Payload: ligoNil, Stack: st.trace(), GoStack: string(debug.Stack())}
+	if len(e.Stack) > 0 {
+		e.Pos = e.Stack[0].Pos
+		if fnName == "" {
+			fnName = e.Stack[0].Name
+		}
+	}
+	if n != nil {
+		e.Pos = n.Pos()
+	}
+	if fnName == "" {
+		fnName = "<top level>"
+	}
+	e.Message = fmt.Sprint(fnName, " : ", r)
+	if len(st.frames) > depth {
+		st.frames = st.frames[:depth]
+	}
+	st.thrown = nil
+	*v, *err = ligoNil, e
+}
+
+// catchClause is a parsed (catch [KIND] VARIABLE BODY...) clause of the try construct
+type catchClause struct {
+	kind string
+	name string
+	body []Node
+}
+
+// parseCatch function is used to parse a catch clause. The kind is optional
+// and the clause catches every kind of exception without it.
+func parseCatch(l *List) (catchClause, error) {
+	args := l.Nodes[1:]
+	c := catchClause{}
+	if len(args) > 0 {
+		if lit, ok := args[0].(*Literal); ok && lit.Value.Type == TypeString {
+			c.kind = lit.Value.Value.(string)
+			args = args[1:]
+		}
+	}
+	if len(args) < 2 {
+		return c, Error("catch : expected (catch [KIND] VARIABLE BODY...)")
+	}
+	name, ok := args[0].(*Symbol)
+	if !ok || !rVariable.MatchString(name.Name) {
+		return c, Error("catch : invalid variable name " + args[0].String())
+	}
+	c.name = name.Name
+	c.body = args[1:]
+	return c, nil
+}
+
+// tryEval method is used to run the try construct. An exception thrown anywhere
+// in the body is handled by the first catch clause matching it's kind, and the
+// finally clause is run at last whatever happens in the body or the handler.
+func (vm *VM) tryEval(tkns []Node) (Variable, error) {
+	body := make([]Node, 0)
+	catches := make([]catchClause, 0)
+	var finally *List
+	for _, n := range tkns[1:] {
+		l, ok := n.(*List)
+		switch {
+		case ok && len(l.Nodes) > 0 && isSymbol(l.Nodes[0], "catch"):
+			if finally != nil {
+				return ligoNil, Error("try : catch clause should be placed before the finally clause")
+			}
+			c, err := parseCatch(l)
+			if err != nil {
+				return ligoNil, err
+			}
+			catches = append(catches, c)
+		case ok && len(l.Nodes) > 0 && isSymbol(l.Nodes[0], "finally"):
+			if finally != nil {
+				return ligoNil, Error("try : can have only one finally clause")
+			}
+			finally = l
+		default:
+			if len(catches) > 0 || finally != nil {
+				return ligoNil, Error("try : body should be placed before the catch and finally clauses")
+			}
+			body = append(body, n)
+		}
+	}
+
+	v, err := vm.evalGuarded(body)
+	if e := vm.catchable(err); e != nil {
+		// the error is left as it is if none of the catch clauses match
+		var cerr error
+		if v, cerr = vm.catchException(catches, e); cerr != e {
+			err = cerr
+		}
+	}
+	if finally != nil {
+		if _, ferr := vm.evalBody(finally.Nodes[1:]); ferr != nil {
+			return ligoNil, ferr
+		}
+	}
+	return v, err
+}
+
+// catchable method returns the exception to be matched with the catch clauses
+// for the error. The runtime errors of the VM (like calling a function which is
+// not defined) are caught as exceptions of the default kind, with the position
+// and the call stack of the error. nil is returned for the errors which can't be
+// caught : return, break and continue, exceeding the budget and the cancellation
+// of the evaluation.
+func (vm *VM) catchable(err error) *Exception {
+	if err == nil {
+		return nil
+	}
+	var e *Exception
+	if errors.As(err, &e) {
+		return e
+	}
+	var ce *cancelError
+	if _, ok := err.(*jump); ok || errors.Is(err, ErrBudgetExceeded) || errors.As(err, &ce) {
+		return nil
+	}
+	e = vm.newException(DefaultExceptionKind, err.Error(), ligoNil)
+	var se *SourceError
+	if errors.As(err, &se) {
+		e.Message, e.Pos, e.Stack = se.Err.Error(), se.Pos, se.Stack
+	}
+	return e
+}
+
+// catchException method is used to run the first catch clause matching the kind
+// of the exception, with the exception bound to the clause's variable. The
+// exception is returned as it is if none of them match.
+func (vm *VM) catchException(catches []catchClause, e *Exception) (Variable, error) {
+	for _, c := range catches {
+		if c.kind != "" && c.kind != e.Kind {
+			continue
+		}
+		scope := vm.NewScope()
+		scope.bind(c.name, Variable{Type: TypeException, Value: e})
+		return scope.evalBody(c.body)
+	}
+	return ligoNil, e
+}
+
+// rethrow method is used to throw a caught exception again. The position and
+// the call stack of the original throw are kept.
+func (vm *VM) rethrow(tkns []Node) (Variable, error) {
+	if len(tkns) != 2 {
+		return ligoNil, Error("rethrow : expected an exception to throw")
+	}
+	v, err := vm.eval(tkns[1])
+	if err != nil {
+		return ligoNil, err
+	}
+	e, ok := v.Value.(*Exception)
+	if v.Type != TypeException || !ok {
+		return ligoNil, Error("rethrow : expected an exception, got " + v.GetTypeString())
+	}
+	return ligoNil, e
+}
+
+// misplacedClause method is used to report a catch or finally clause used outside a try
+func (vm *VM) misplacedClause(tkns []Node) (Variable, error) {
+	return ligoNil, Error(tkns[0].String() + " : can be used only as a clause of try")
+}
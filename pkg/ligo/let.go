@@ -0,0 +1,113 @@
+package ligo
+
+// binding is a parsed (NAME VALUE) pair of the let constructs
+type binding struct {
+	name  string
+	value Node
+}
+
+// parseBindings function is used to parse the bindings of a let construct,
+// a list (or an array) of (NAME VALUE) pairs like ((a 1) (b 2)).
+func parseBindings(form string, n Node) ([]binding, error) {
+	var nodes []Node
+	switch n := n.(type) {
+	case *List:
+		nodes = n.Nodes
+	case *Array:
+		nodes = n.Nodes
+	default:
+		return nil, Error(form + " : expected a list of bindings like ((NAME VALUE)...), got " + n.String())
+	}
+	bindings := make([]binding, 0, len(nodes))
+	for _, val := range nodes {
+		pair, ok := val.(*List)
+		if !ok || len(pair.Nodes) != 2 {
+			return nil, Error(form + " : expected a binding like (NAME VALUE), got " + val.String())
+		}
+		name, ok := pair.Nodes[0].(*Symbol)
+		if !ok || !rVariable.MatchString(name.Name) {
+			return nil, Error(form + " : invalid variable name " + pair.Nodes[0].String())
+		}
+		bindings = append(bindings, binding{name: name.Name, value: pair.Nodes[1]})
+	}
+	return bindings, nil
+}
+
+// letForm method is used to parse the passed let construct and return it's
+// bindings and the scope of the body, which is a child scope of the current one.
+func (vm *VM) letForm(tkns []Node) ([]binding, *VM, error) {
+	form := tkns[0].String()
+	if len(tkns) < 3 {
+		return nil, nil, Error(form + " : expected (" + form + " ((NAME VALUE)...) BODY...)")
+	}
+	bindings, err := parseBindings(form, tkns[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return bindings, vm.NewScope(), nil
+}
+
+// letEval method is used to run the let construct. The values are evaluated in
+// the current scope and bound in a new scope, visible only inside the body.
+func (vm *VM) letEval(tkns []Node) (Variable, error) {
+	bindings, scope, err := vm.letForm(tkns)
+	if err != nil {
+		return ligoNil, err
+	}
+	values := make([]Variable, 0, len(bindings))
+	for _, b := range bindings {
+		v, err := vm.eval(b.value)
+		if err != nil {
+			return ligoNil, err
+		}
+		values = append(values, v)
+	}
+	for i, b := range bindings {
+		if _, ok := scope.getLocal(b.name); ok {
+			return ligoNil, Error("let : variable '" + b.name + "' bound more than once")
+		}
+		scope.bind(b.name, values[i])
+	}
+	return scope.tailBody(tkns[2:])
+}
+
+// letStarEval method is used to run the let* construct. The bindings are done one
+// after another, so a value can use the variables bound before it.
+func (vm *VM) letStarEval(tkns []Node) (Variable, error) {
+	bindings, scope, err := vm.letForm(tkns)
+	if err != nil {
+		return ligoNil, err
+	}
+	for _, b := range bindings {
+		v, err := scope.eval(b.value)
+		if err != nil {
+			return ligoNil, err
+		}
+		scope.bind(b.name, v)
+	}
+	return scope.tailBody(tkns[2:])
+}
+
+// letrecEval method is used to run the letrec construct. All the variables are
+// bound (to nil) before the values are evaluated in the new scope, so that the
+// functions bound can refer to themselves and to each other.
+func (vm *VM) letrecEval(tkns []Node) (Variable, error) {
+	bindings, scope, err := vm.letForm(tkns)
+	if err != nil {
+		return ligoNil, err
+	}
+	for _, b := range bindings {
+		if _, ok := scope.getLocal(b.name); ok {
+			return ligoNil, Error("letrec : variable '" + b.name + "' bound more than once")
+		}
+		scope.bind(b.name, ligoNil)
+	}
+	for _, b := range bindings {
+		v, err := scope.eval(b.value)
+		if err != nil {
+			return ligoNil, err
+		}
+		scope.bind(b.name, v)
+	}
+	return scope.tailBody(tkns[2:])
+}
@@ -1,6 +1,7 @@
 package ligo
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,6 +9,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 // escape sequences to be replaced with the counterpart in a string
@@ -22,7 +25,7 @@ var escapeSequences = map[string]int{
 	"\\t":  0x09,
 	"\\v":  0x0B,
 	"\\'":  0x27,
-	"\"":   0x22,
+	"\\\"": 0x22,
 	"\\ ":  0x20,
 	"\\!":  0x21,
 	"\\#":  0x23,
@@ -38,44 +41,73 @@ var escapeSequences = map[string]int{
 	"\\~":  0x7E,
 }
 
-// TODO : add escape sequence handling for hex and octal sequences...
-func reformEscapes(str string) string {
-	ret := ""
-	isEscape := false
-	for _, val := range str {
-		switch val {
-		case '\\':
-			if !isEscape {
-				isEscape = true
-			} else {
-				ret += string(0x5C)
-				isEscape = false
+// reformEscapes is used to replace the escape sequences in the passed string
+// with the characters they denote. Along with the fixed escape sequences, hex
+// (\xNN), octal (\NNN) and unicode (\uXXXX, \UXXXXXXXX) sequences are handled.
+// For an unknown or malformed escape sequence, the offset of the sequence in the
+// passed string is returned along with the error.
+func reformEscapes(str string) (string, int, error) {
+	ret := make([]byte, 0, len(str))
+	for i := 0; i < len(str); i++ {
+		if str[i] != '\\' {
+			ret = append(ret, str[i])
+			continue
+		}
+		if i+1 >= len(str) {
+			return "", i, Error("Incomplete escape sequence at the end of the string")
+		}
+		val := str[i+1]
+		switch {
+		case val == 'x', val == 'u', val == 'U':
+			digits := 2
+			if val == 'u' {
+				digits = 4
+			} else if val == 'U' {
+				digits = 8
 			}
-		default:
-			if !isEscape {
-				ret += string(val)
+			if i+2+digits > len(str) {
+				return "", i, Error("Incomplete escape sequence : '" + str[i:] + "'")
+			}
+			num, err := strconv.ParseUint(str[i+2:i+2+digits], 16, 32)
+			if err != nil {
+				return "", i, Error("Invalid hex digits in the escape sequence : '" + str[i:i+2+digits] + "'")
+			}
+			if val == 'x' {
+				ret = append(ret, byte(num))
 			} else {
-				es := "\\" + string(val)
-				num, ok := escapeSequences[es]
-				if !ok {
-					panic("in :\n\t" + str + "\nUnknown Escape sequence. : '\\" + string(val) + "'")
+				if !utf8.ValidRune(rune(num)) {
+					return "", i, Error("Invalid unicode code point in the escape sequence : '" + str[i:i+2+digits] + "'")
 				}
-				ret += string(num)
-				isEscape = false
+				ret = append(ret, string(rune(num))...)
+			}
+			i += 1 + digits
+		case val >= '0' && val <= '7':
+			end := i + 1
+			for end < len(str) && end < i+4 && str[end] >= '0' && str[end] <= '7' {
+				end++
+			}
+			num, _ := strconv.ParseUint(str[i+1:end], 8, 32)
+			if num > 0xFF {
+				return "", i, Error("Octal escape sequence out of range : '" + str[i:end] + "'")
 			}
+			ret = append(ret, byte(num))
+			i = end - 1
+		default:
+			num, ok := escapeSequences["\\"+string(val)]
+			if !ok {
+				return "", i, Error("Unknown Escape sequence : '\\" + string(val) + "'")
+			}
+			ret = append(ret, byte(num))
+			i++
 		}
 	}
-	return ret
+	return string(ret), 0, nil
 }
 
 // regexp variables for matching the syntax of the script
 var rInteger = regexp.MustCompile(`^[+-]?[0-9]+$`)
 var rFloat = regexp.MustCompile(`^[+-]?[0-9]*\.[0-9]+$`)
-var rString = regexp.MustCompile(`^\".*\"$`)
 var rVariable = regexp.MustCompile(`^[[:alpha:]]+[[:alnum:]]*$`)
-var rExpression = regexp.MustCompile(`^\\(.*\\)$`)
-var rClosure = regexp.MustCompile(`^\\|([[:alpha:]]+[[:alnum:]]*\\s*)*\\.\\.\\.([[:alpha:]]+[[:alnum:]]*\\s*){0,1}|$`)
-var rArray = regexp.MustCompile(`^\\[(.*\\s*)*\\]$`)
 
 // Variable is a struct denoting a value in the VM
 type Variable struct {
@@ -101,10 +133,38 @@ func (v Variable) GetTypeString() (tp string) {
 		tp = "array"
 	case TypeMap:
 		tp = "map"
+	case TypeStruct:
+		tp = "struct"
 	case TypeIFunc:
 		tp = "inbuilt function"
 	case TypeDFunc:
 		tp = "defined function"
+	case TypeException:
+		tp = "exception"
+	case TypeExp:
+		tp = "expression"
+	case TypeSymbol:
+		tp = "symbol"
+	case TypeList:
+		tp = "list"
+	case TypeMacro:
+		tp = "macro"
+	case TypeRecordType:
+		tp = "record type"
+	case TypeTask:
+		tp = "task"
+	case TypeChannel:
+		tp = "channel"
+	case TypeActor:
+		tp = "actor"
+	case TypeRecord:
+		if r, ok := v.Value.(*Record); ok {
+			tp = r.Type.Name
+		}
+	default:
+		if info := registeredType(v.Type); info != nil {
+			tp = info.Name
+		}
 	}
 	return
 }
@@ -112,13 +172,20 @@ func (v Variable) GetTypeString() (tp string) {
 // String method implements the Stringer interface for the Variable type
 func (v Variable) String() string {
 	typeString := "Variable {Type : <" + v.GetTypeString()
+	if str, ok := FormatValue(v); ok {
+		return typeString + "> ,Value : " + str + "}"
+	}
 	return typeString + fmt.Sprint("> ,Value : ", v.Value, "}")
 }
 
-// Defined struct contains variables needed for storing a function defined in ligo script itself
+// Defined struct contains variables needed for storing a function defined in ligo script itself.
+// The scope in which the function is defined is captured, so that the function body
+// can access the variables of the defining scope even after it is returned (closure).
 type Defined struct {
+	name      string
 	scopevars []string
-	eval      string
+	body      Node
+	env       *VM
 }
 
 // InBuilt type is a function format that is callable from the ligo script
@@ -127,25 +194,206 @@ type InBuilt func(*VM, ...Variable) Variable
 // Map type is a ligo equivalent for dictionaly or hash maps
 type Map map[Variable]Variable
 
-// ProcessCommon is a struct type for process control and signal dispatch
+// ProcessCommon is a struct type for the process control shared by all the
+// scopes of a VM. The evaluations are interrupted through their context, and
+// limited by the budget.
 type ProcessCommon struct {
-	interrupt bool
-	*sync.Mutex
+	budget      Budget
+	memoryLimit int64
+	maxDepth    int
+	stopped     int32
+	self        *Actor
+	loader      PackageLoader
+	modules     map[string]*module
+	last        Usage
+	total       Usage
+	sync.Mutex
+}
+
+// typeTailCall is the type of the value returned by the constructs for a node
+// in tail position. It is never visible to the ligo code.
+const typeTailCall Type = -0x100
+
+// tailCall struct contains the node to be evaluated in tail position, the scope
+// to evaluate it in and the call frame if it is a function body.
+type tailCall struct {
+	vm    *VM
+	node  Node
+	frame *Frame
+}
+
+// jumpKind denotes the kind of a non-local jump in the control flow
+type jumpKind int
+
+const (
+	jumpReturn jumpKind = iota
+	jumpBreak
+	jumpContinue
+)
+
+// jump is returned as an error by return, break and continue to unwind the
+// evaluation till the enclosing function or loop. It is not a ligo exception,
+// so it can't be caught by the scripts.
+type jump struct {
+	kind  jumpKind
+	label string
+	value Variable
+	pos   Pos
+}
+
+// Error method implements the error interface for the type jump. The message
+// is reported when there is no enclosing construct to jump to.
+func (j *jump) Error() string {
+	switch j.kind {
+	case jumpReturn:
+		return "return : used outside of a function"
+	case jumpBreak:
+		if j.label != "" {
+			return "break : no enclosing loop labelled :" + j.label
+		}
+		return "break : used outside of a loop"
+	}
+	if j.label != "" {
+		return "continue : no enclosing loop labelled :" + j.label
+	}
+	return "continue : used outside of a loop"
+}
+
+// stray method is used to convert a jump with no target into an error
+// with the position of the jump and the current call stack.
+func (j *jump) stray(st *evalState) error {
+	return &SourceError{Pos: j.pos, Err: Error(j.Error()), Stack: st.trace()}
+}
+
+// loopControl function is used to check the error returned by the body of a loop
+// with the passed label. exit is true if the loop has to be stopped. A break or
+// continue for this loop is consumed, any other error is returned as it is.
+func loopControl(err error, label string) (exit bool, rerr error) {
+	j, ok := err.(*jump)
+	if !ok || j.kind == jumpReturn || (j.label != "" && j.label != label) {
+		return true, err
+	}
+	return j.kind == jumpBreak, nil
+}
+
+// loopLabel function is used to split the optional label (like :outer) from the
+// arguments of a loop construct. The returned nodes don't contain the label.
+func loopLabel(tkns []Node) (string, []Node) {
+	if len(tkns) > 1 {
+		if s, ok := tkns[1].(*Symbol); ok && len(s.Name) > 1 && s.Name[0] == ':' {
+			return s.Name[1:], append([]Node{tkns[0]}, tkns[2:]...)
+		}
+	}
+	return "", tkns
+}
+
+// keyword is a function format for the language constructs that are
+// handled by the VM itself. The nodes are passed without evaluation.
+type keyword func(*VM, []Node) (Variable, error)
+
+// keywordHandler contains the handlers for all the language constructs
+var keywordHandler map[string]keyword
+
+// builtins contains the in-built functions of the language itself. They are
+// found after the bindings of the scopes, so a script can define it's own
+// function of the same name.
+var builtins map[string]InBuilt
+
+func init() {
+	keywordHandler = map[string]keyword{
+		"var":         (*VM).newVar,
+		"set":         (*VM).setVar,
+		"fn":          (*VM).setFn,
+		"return":      (*VM).returnArg,
+		"break":       (*VM).breakLoop,
+		"continue":    (*VM).continueLoop,
+		"progn":       (*VM).runExpressions,
+		"loop":        (*VM).runLoop,
+		"in":          (*VM).runIn,
+		"if":          (*VM).ifClause,
+		"match":       (*VM).matchClause,
+		"eval":        (*VM).evalString,
+		"fork":        (*VM).fork,
+		"delete":      (*VM).deleteVar,
+		"namespace":   (*VM).namespaceEval,
+		"lambda":      (*VM).lambdaEval,
+		"struct":      (*VM).structEval,
+		"struct-with": (*VM).structWith,
+		"defstruct":   (*VM).defStruct,
+		"deftype":     (*VM).defStruct,
+		"try":         (*VM).tryEval,
+		"catch":       (*VM).misplacedClause,
+		"finally":     (*VM).misplacedClause,
+		"rethrow":     (*VM).rethrow,
+
+		"quote":            (*VM).quoteEval,
+		"quasiquote":       (*VM).quasiquoteEval,
+		"unquote":          (*VM).misplacedUnquote,
+		"unquote-splicing": (*VM).misplacedUnquote,
+		"defmacro":         (*VM).defMacro,
+		"macroexpand":      (*VM).macroExpand,
+
+		"let":    (*VM).letEval,
+		"let*":   (*VM).letStarEval,
+		"letrec": (*VM).letrecEval,
+
+		"and":    (*VM).andEval,
+		"or":     (*VM).orEval,
+		"cond":   (*VM).condEval,
+		"when":   (*VM).whenEval,
+		"unless": (*VM).unlessEval,
+
+		"select": (*VM).selectEval,
+
+		"spawn-vm": (*VM).spawnVM,
+
+		"import": (*VM).importEval,
+		"export": (*VM).exportEval,
+	}
+
+	builtins = map[string]InBuilt{
+		"join":     (*VM).join,
+		"join-all": (*VM).joinAll,
+		"join-any": (*VM).joinAny,
+		"done?":    (*VM).taskDone,
+		"cancel":   (*VM).cancelTask,
+
+		"chan-new":   (*VM).chanNew,
+		"chan-send":  (*VM).chanSend,
+		"chan-recv":  (*VM).chanRecv,
+		"chan-close": (*VM).chanClose,
+
+		"send":    (*VM).send,
+		"receive": (*VM).receive,
+		"self":    (*VM).selfActor,
+	}
 }
 
 // VM struct is a State Struct contains all the variable maps,
-// defined function maps, in-built function maps and a global
-// scope pointing to the global Scope VM
+// defined function maps, in-built function maps and a parent
+// scope pointing to the enclosing scope VM. The scopes form a chain
+// (function -> enclosing function -> namespace -> global) which is
+// walked for the lookup and update of variables.
+//
+// A VM can be evaluated from many goroutines at once. Each evaluation started
+// from go has it's own call stack, and the scopes are locked when they are read
+// or updated. The maps (Vars, Funcs and LFuncs) should be written directly only
+// before the VM is shared, use Define or LoadPlugin after that. The limits
+// (SetBudget, SetMemoryLimit and SetMaxCallDepth) are not locked, and should be
+// set only before the VM is shared too.
 type VM struct {
-	global         *VM
-	exception      string
-	Vars           map[string]Variable
-	Funcs          map[string]InBuilt
-	LFuncs         map[string]Defined
-	namespaces     map[string]*VM
-	pc             *ProcessCommon
-	keywordHandler map[string]func([]string) (Variable, error)
-	isNamespace    bool
+	parent     *VM
+	Vars       map[string]Variable
+	Funcs      map[string]InBuilt
+	LFuncs     map[string]Defined
+	namespaces map[string]*VM
+	mu         *sync.RWMutex
+	pc         *ProcessCommon
+	state      *evalState
+	// module is the module loaded in the scope, if it is the scope of a module
+	module *module
+	// view is the module seen through the scope, if it is the namespace of an import
+	view *moduleView
 }
 
 // NewVM returns a new VM object pointer after initializing the values
@@ -154,173 +402,216 @@ func NewVM() *VM {
 	vm.Vars = make(map[string]Variable)
 	vm.Funcs = make(map[string]InBuilt)
 	vm.LFuncs = make(map[string]Defined)
-	vm.global = nil
-	vm.pc = &ProcessCommon{Mutex: &sync.Mutex{}, interrupt: false}
-	vm.keywordHandler = map[string]func([]string) (Variable, error){
-		"var":       vm.newVar,
-		"set":       vm.setVar,
-		"fn":        vm.setFn,
-		"return":    vm.returnArg,
-		"progn":     vm.runExpressions,
-		"loop":      vm.runLoop,
-		"in":        vm.runIn,
-		"if":        vm.ifClause,
-		"match":     vm.matchClause,
-		"eval":      vm.evalString,
-		"fork":      vm.fork,
-		"delete":    vm.deleteVar,
-		"namespace": vm.namespaceEval,
-		"lambda":    vm.lambdaEval,
-		"struct":    vm.structEval,
-	}
+	vm.parent = nil
+	vm.pc = &ProcessCommon{maxDepth: DefaultMaxCallDepth}
+	vm.state = newEvalState(context.Background())
 	vm.namespaces = make(map[string]*VM)
-	vm.isNamespace = false
+	vm.mu = &sync.RWMutex{}
 	return vm
 }
 
-// Stop method is used to stop the current process and return an error value.
-func (vm *VM) Stop() {
-	vm.pc.Lock()
-	vm.pc.interrupt = true
-}
-
-// Resume method is used to resume the normal evaluation by releasing the lock
-// on the mutex of the process control. This should never be called in this package
-// itself. Resume should be used only when a error returned is ErrSignalRecieved
-// in the main package. See the sample interpreter implementation in
-// https://github.com/aki237/ligo/tree/master/cmd/ligo.
-func (vm *VM) Resume() {
-	vm.pc.Unlock()
-	vm.pc.interrupt = false
-}
-
-// parseToArray is used to parse the given string into ligo.TypeArray
-func (vm *VM) parseToArray(token string) (Variable, error) {
-	ar := token[1:MatchChars(token, 0, '[', ']')]
-	tkns, err := ScanTokens("(" + ar + ")")
-	if err != nil {
-		return ligoNil, err
-	}
-	vars := make([]Variable, 0)
-	for _, val := range tkns {
-		v, err := vm.GetVariable(val)
+// evalArray is used to evaluate the items of an array node into ligo.TypeArray
+func (vm *VM) evalArray(n *Array) (Variable, error) {
+	vars := make([]Variable, 0, len(n.Nodes))
+	for _, val := range n.Nodes {
+		v, err := vm.eval(val)
 		if err != nil {
 			return ligoNil, err
 		}
 		vars = append(vars, v)
 	}
-	retVars := Variable{Type: TypeArray, Value: vars}
-	return retVars, nil
-}
-
-// parseToInt method is used to parse a given string to ligo.TypeInt
-func (vm *VM) parseToInt(token string) (Variable, error) {
-	num, err := strconv.ParseInt(token, 10, 64)
-	if err != nil {
+	array := Variable{Type: TypeArray, Value: vars}
+	if err := vm.alloc(array); err != nil {
 		return ligoNil, err
 	}
-	return Variable{Type: TypeInt, Value: num}, nil
-}
-
-// parseToFloat method is used to parse a given string to ligo.TypeFloat
-func (vm *VM) parseToFloat(token string) (Variable, error) {
-	num, err := strconv.ParseFloat(token, 64)
-	if err != nil {
-		return ligoNil, err
-	}
-	return Variable{Type: TypeFloat, Value: num}, nil
-}
-
-// parseToString method is used to reform escape sequences and
-// return a ligo.TypeString
-func (vm *VM) parseToString(token string) (Variable, error) {
-	token = reformEscapes(token)
-	return Variable{Type: TypeString, Value: token[1 : len(token)-1]}, nil
+	return array, nil
 }
 
 func getStructVar(strct Variable, key string) (Variable, error) {
-	keys, ok := strct.Value.(map[string]Variable)
-	if strct.Type != TypeStruct || !ok {
-		return ligoNil, Error("passed variable is not a struct and doesn't have a member named '" + key + "'")
+	var keys map[string]Variable
+	if e, isException := strct.Value.(*Exception); isException && strct.Type == TypeException {
+		keys = e.fields()
+	} else {
+		var err error
+		if keys, err = structFields(strct, key); err != nil {
+			return ligoNil, err
+		}
 	}
 	varName := key
 	if strings.Contains(key, ":") {
 		varName = strings.Split(key, ":")[0]
-		v, ok := keys[varName]
+		v, ok := member(keys, varName)
 		if !ok {
 			return ligoNil, Error("no such key found in the struct : \"" + key + "\"")
 		}
 		return getStructVar(v, strings.Join(strings.Split(key, ":")[1:], ":"))
 	}
-	v, ok := keys[varName]
+	v, ok := member(keys, varName)
 	if !ok {
 		return ligoNil, Error("no such key found in the struct : \"" + key + "\"")
 	}
 	return v, nil
 }
 
-// parseToSymbol method is used to fetch the variable from the VM.
-// If the variable is not found, it checks whether a function is found
-// and returns it.
-func (vm *VM) parseToSymbol(token string) (Variable, error) {
-	varFromVM, ok := vm.Vars[token]
-	if ok {
-		return Variable{Type: varFromVM.Type, Value: varFromVM.Value}, nil
+// lookup method is used to find the nearest binding of the passed name in
+// the scope chain. The value and the scope holding it are returned.
+func (vm *VM) lookup(name string) (Variable, *VM, bool) {
+	for scope := vm; scope != nil; scope = scope.parent {
+		if v, holder, ok := scope.binding(name); ok {
+			return v, holder, true
+		}
 	}
+	return ligoNil, nil, false
+}
 
-	if strings.Contains(token, ":") {
-		varName := strings.Split(token, ":")[0]
-		v, err := vm.parseToSymbol(varName)
-		if err != nil {
-			return v, err
+// binding method is used to get the binding of the passed name in the current
+// scope, or in the module seen through it. The scope holding it is returned too.
+func (vm *VM) binding(name string) (Variable, *VM, bool) {
+	if v, ok := vm.getLocal(name); ok {
+		return v, vm, true
+	}
+	if vm.view != nil {
+		if v, ok := vm.view.binding(name); ok {
+			return v, vm.view.scope, true
 		}
-		return getStructVar(v, strings.Join(strings.Split(token, ":")[1:], ":"))
 	}
+	return ligoNil, nil, false
+}
 
-	nss := strings.Split(token, ".")
+// getLocal method is used to get the binding of the passed name from the
+// current scope alone. Variables, inbuilt and defined functions are checked.
+func (vm *VM) getLocal(name string) (Variable, bool) {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+	return vm.local(name)
+}
 
-	if len(strings.Split(token, ".")) > 1 {
-		ns := nss[0]
-		fn := strings.Join(nss[1:], ".")
-		namespace, ok := vm.namespaces[ns]
-		if ok {
-			return namespace.parseToSymbol(fn)
-		}
+// local method is used to get the binding of the passed name from the current
+// scope, which should be locked by the caller.
+func (vm *VM) local(name string) (Variable, bool) {
+	if v, ok := vm.Vars[name]; ok {
+		return v, true
+	}
+	if fnc, ok := vm.Funcs[name]; ok {
+		return Variable{Type: TypeIFunc, Value: fnc}, true
 	}
+	if fnc, ok := vm.LFuncs[name]; ok {
+		return Variable{Type: TypeDFunc, Value: fnc}, true
+	}
+	return ligoNil, false
+}
 
-	function, err := vm.parseToFunc(token)
-	if err == nil {
-		return function, nil
+// bind method is used to store the value of the passed name in the current scope.
+// Functions are stored in the function maps and others in the variable map.
+func (vm *VM) bind(name string, v Variable) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.store(name, v)
+}
+
+// define method is used to bind the passed name in the current scope only if it
+// is not bound in it already. false is returned if it is.
+func (vm *VM) define(name string, v Variable) bool {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if _, ok := vm.local(name); ok {
+		return false
 	}
+	vm.store(name, v)
+	return true
+}
 
-	if vm.global == nil {
-		return ligoNil, ErrNoVariable + Error(" : "+token)
+// Define method is used to bind the passed name to the value in the scope of the
+// VM, replacing the binding it had. Unlike writing the maps directly, it can be
+// called while the VM is being evaluated in other goroutines.
+func (vm *VM) Define(name string, v Variable) {
+	vm.bind(name, v)
+}
+
+// store method is used to bind the passed name in the current scope, which
+// should be locked by the caller.
+func (vm *VM) store(name string, v Variable) {
+	vm.remove(name)
+	switch v.Type {
+	case TypeIFunc:
+		vm.Funcs[name] = v.Value.(InBuilt)
+	case TypeDFunc:
+		vm.LFuncs[name] = v.Value.(Defined)
+	default:
+		vm.Vars[name] = v
 	}
+}
 
-	return vm.global.parseToSymbol(token)
+// unbind method is used to remove the binding of the passed name from the current scope
+func (vm *VM) unbind(name string) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.remove(name)
 }
 
-// parseToFunc method is used to find the function from the vm and return it.
-func (vm *VM) parseToFunc(token string) (Variable, error) {
-	if fnc, ok := vm.Funcs[token]; ok {
+// remove method is used to remove the binding of the passed name from the current
+// scope, which should be locked by the caller.
+func (vm *VM) remove(name string) {
+	delete(vm.Vars, name)
+	delete(vm.Funcs, name)
+	delete(vm.LFuncs, name)
+}
+
+// findNamespace method is used to find the namespace of the passed name in the scope chain
+func (vm *VM) findNamespace(ns string) (*VM, bool) {
+	for scope := vm; scope != nil; scope = scope.parent {
+		if namespace := scope.GetNameSpace(ns); namespace != nil {
+			return namespace, true
+		}
+	}
+	return nil, false
+}
+
+// parseToSymbol method is used to fetch the variable or function from the VM.
+// The scope chain is searched for the name first. Then struct member access
+// (struct:Member) and namespaced names (namespace.name) are resolved.
+func (vm *VM) parseToSymbol(token string) (Variable, error) {
+	if v, _, ok := vm.lookup(token); ok {
+		return v, nil
+	}
+	if fnc, ok := builtins[token]; ok {
 		return Variable{Type: TypeIFunc, Value: fnc}, nil
 	}
-	if fnc, ok := vm.LFuncs[token]; ok {
-		return Variable{Type: TypeDFunc, Value: fnc}, nil
+
+	if strings.Contains(token, ":") {
+		varName := strings.Split(token, ":")[0]
+		v, err := vm.parseToSymbol(varName)
+		if err != nil {
+			return v, err
+		}
+		return getStructVar(v, strings.Join(strings.Split(token, ":")[1:], ":"))
 	}
 
 	nss := strings.Split(token, ".")
-
-	if len(strings.Split(token, ".")) > 1 {
-		ns := nss[0]
-		fn := strings.Join(nss[1:], ".")
-		namespace, ok := vm.namespaces[ns]
-		if ok {
-			return namespace.parseToFunc(fn)
+	if len(nss) > 1 {
+		if namespace, ok := vm.findNamespace(nss[0]); ok {
+			if v, err := namespace.parseInNamespace(strings.Join(nss[1:], ".")); err == nil {
+				return v, nil
+			}
 		}
 	}
 
-	return ligoNil, ErrFuncNotFound + Error(" : "+token)
+	return ligoNil, ErrNoVariable + Error(" : "+token)
+}
+
+// parseInNamespace method is used to fetch a variable or function defined in the
+// namespace itself (or in a namespace nested inside it).
+func (vm *VM) parseInNamespace(token string) (Variable, error) {
+	if v, _, ok := vm.binding(token); ok {
+		return v, nil
+	}
+	nss := strings.Split(token, ".")
+	if len(nss) > 1 {
+		if namespace := vm.GetNameSpace(nss[0]); namespace != nil {
+			return namespace.parseInNamespace(strings.Join(nss[1:], "."))
+		}
+	}
+	return ligoNil, ErrNoVariable + Error(" : "+token)
 }
 
 // GetVariable method is used to process the token string passed and get the
@@ -328,168 +619,147 @@ func (vm *VM) parseToFunc(token string) (Variable, error) {
 // as, if the token passed is a sub expression this method knows to evaluate and
 // return the value of that sub expression.
 func (vm *VM) GetVariable(token string) (Variable, error) {
-	if len(token) < 1 {
+	vm = vm.evaluation()
+	nodes, err := Parse(token)
+	if err != nil {
+		return ligoNil, err
+	}
+	if len(nodes) != 1 {
 		return ligoNil, Error("invalid Token passed")
 	}
-	switch true {
-	case MatchChars(token, 0, '[', ']') > 0:
-		return vm.parseToArray(token)
-	case MatchChars(token, 0, '(', ')') > 0:
-		return vm.Eval(token)
-	case rInteger.MatchString(token):
-		return vm.parseToInt(token)
-	case rFloat.MatchString(token):
-		return vm.parseToFloat(token)
-	case rString.MatchString(token) || token[0] == '"':
-		return vm.parseToString(token)
-	case token == "true":
-		return Variable{Type: TypeBool, Value: true}, nil
-	case token == "false":
-		return Variable{Type: TypeBool, Value: false}, nil
-	}
-	return vm.parseToSymbol(token)
+	return vm.eval(nodes[0])
 }
 
 // setFn is used to parse a ligo function construct and store it in the
 // current scope. It also warns if the function is already declared.
-func (vm *VM) setFn(tokens []string) (Variable, error) {
+func (vm *VM) setFn(tokens []Node) (Variable, error) {
 	if len(tokens) != 4 {
 		return ligoNil, Error("A function construct can only have a single returning function")
 	}
-	fnName := tokens[1]
-	if _, ok := vm.Funcs[fnName]; ok {
+	fnName := tokens[1].String()
+	vm.mu.RLock()
+	_, inbuilt := vm.Funcs[fnName]
+	_, defined := vm.LFuncs[fnName]
+	vm.mu.RUnlock()
+	if inbuilt {
 		fmt.Printf("Warning : function \"%s\" has already been declared as an InBuilt function.\n", fnName)
 	}
-	if _, ok := vm.LFuncs[fnName]; ok {
+	if defined {
 		fmt.Printf("Warning : function \"%s\" has already been declared as an Ligo function.\n", fnName)
 	}
-	if !rClosure.MatchString(tokens[2]) {
-		return ligoNil,
-			Error("Expected parameter name in the function definition " + fnName + " closure : " + tokens[2])
+	varNames, err := getVarsFromClosure(tokens[2])
+	if err != nil {
+		return ligoNil, Error("In the function definition " + fnName + " : " + err.Error())
 	}
-	varNames := getVarsFromClosure(tokens[2])
-	fn := Defined{scopevars: varNames, eval: tokens[3]}
-	vm.LFuncs[fnName] = fn
+	fn := Defined{name: fnName, scopevars: varNames, body: tokens[3], env: vm}
+	vm.bind(fnName, Variable{Type: TypeDFunc, Value: fn})
 	return ligoNil, nil
 }
 
-// setVar method is used to set a value to a variable.
-// If the variable is not defined already, this will throw an error.
-func (vm *VM) setVar(tokens []string) (Variable, error) {
+// setVar method is used to set a value to a variable, or to a member of a struct
+// (like p:Address:City). If the variable is not defined already, this will throw an error.
+func (vm *VM) setVar(tokens []Node) (Variable, error) {
 	if len(tokens) != 3 {
 		return ligoNil, Error("Wrong number of arguments to the keyword.")
 	}
-	if !rVariable.MatchString(tokens[1]) {
+	name := tokens[1].String()
+	if strings.Contains(name, ":") {
+		v, err := vm.eval(tokens[2])
+		if err != nil {
+			return ligoNil, err
+		}
+		return vm.setField(name, v)
+	}
+	if !rVariable.MatchString(name) {
 		return ligoNil, Error("Wrong token found in the variable name")
 	}
-	v, err := vm.GetVariable(tokens[2])
+	v, err := vm.eval(tokens[2])
 	if err != nil {
 		return ligoNil, err
 	}
+	return vm.setVariable(name, v)
+}
 
-	switch v.Type {
-	case TypeIFunc:
-		_, ok := vm.Funcs[tokens[1]]
-		if !ok {
-			return ligoNil, Error("Variable not defined. Try \"var\" for creating a new variable")
-		}
-		vm.Funcs[tokens[1]] = v.Value.(InBuilt)
-		return ligoNil, nil
-	case TypeDFunc:
-		_, ok := vm.LFuncs[tokens[1]]
-		if !ok {
-			return ligoNil, Error("Variable not defined. Try \"var\" for creating a new variable")
-		}
-		vm.LFuncs[tokens[1]] = v.Value.(Defined)
-		return ligoNil, nil
-	}
-
-	_, ok := vm.Vars[tokens[1]]
-	if ok {
-		vm.Vars[tokens[1]] = v
-		return ligoNil, nil
-	}
-	if vm.global == nil {
-		return ligoNil, Error("Variable '" + tokens[1] + "' not defined. Try \"var\" for creating a new variable")
+// setVariable method is used to update the nearest binding of the passed name
+// in the scope chain.
+func (vm *VM) setVariable(name string, v Variable) (Variable, error) {
+	_, scope, ok := vm.lookup(name)
+	if !ok {
+		return ligoNil, Error("Variable '" + name + "' not defined. Try \"var\" for creating a new variable")
 	}
-	return vm.global.setVar(tokens)
+	scope.bind(name, v)
+	return ligoNil, nil
 }
 
-// newVar method is used to declare a new variable in the VM and set a value to it.
-func (vm *VM) newVar(tokens []string) (Variable, error) {
+// newVar method is used to declare a new variable in the innermost scope and set a value to it.
+// A variable of the same name in an enclosing scope is shadowed.
+func (vm *VM) newVar(tokens []Node) (Variable, error) {
 	if len(tokens) != 3 {
 		return ligoNil, Error("Wrong number of arguments to the keyword.")
 	}
-	if !rVariable.MatchString(tokens[1]) {
+	name := tokens[1].String()
+	if !rVariable.MatchString(name) {
 		return ligoNil, Error("Wrong token found in the variable name")
 	}
-	v, err := vm.GetVariable(tokens[2])
+	v, err := vm.eval(tokens[2])
 	if err != nil {
 		return ligoNil, err
 	}
-	switch v.Type {
-	case TypeIFunc:
-		vm.Funcs[tokens[1]] = v.Value.(InBuilt)
-		return ligoNil, nil
-	case TypeDFunc:
-		vm.LFuncs[tokens[1]] = v.Value.(Defined)
-		return ligoNil, nil
-	}
-	_, ok := vm.Vars[tokens[1]]
-	if ok {
-		return ligoNil, Error("Variable '" + tokens[1] + "' already defined. Try \"set\" for updating variables")
+	if !vm.define(name, v) {
+		return ligoNil, Error("Variable '" + name + "' already defined. Try \"set\" for updating variables")
 	}
-	vm.Vars[tokens[1]] = v
 	return ligoNil, nil
 }
 
-// getInBuiltFunction method is a small helper method to get the inbuilt function.
-// If found it returns the inbuilt and true, else nil and false.
-func (vm *VM) getInBuiltFunction(fnName string) (InBuilt, bool) {
-	fn, found := vm.Funcs[fnName]
-	if found {
-		return fn, found
-	}
-	v, err := vm.parseToSymbol(fnName)
-	if err != nil {
-		return nil, false
-	}
-	if v.Type != TypeIFunc {
-		return nil, false
-	}
-	return v.Value.(InBuilt), true
-}
-
 // runInBuiltFunction method is a small helper method to run the passed inbuilt function
 // with the passed variables.
-func (vm *VM) runInBuiltFunction(function InBuilt, vars []Variable) (Variable, error) {
-	return function(vm, vars...), nil
-}
-
-// getDefinedFunction method is a small helper method to get the defined function.
-// If found it returns the inbuilt and true, else nil and false.
-func (vm *VM) getDefinedFunction(fnName string) (Defined, bool) {
-	fn, found := vm.LFuncs[fnName]
-	if found {
-		return fn, found
+// A panic in the function is recovered and raised as an exception.
+func (vm *VM) runInBuiltFunction(fnName string, function InBuilt, vars []Variable) (v Variable, err error) {
+	defer vm.recoverPanic(fnName, nil, len(vm.state.frames), &v, &err)
+	if err := vm.charge(); err != nil {
+		return ligoNil, err
 	}
-	v, err := vm.parseToSymbol(fnName)
-	if err != nil {
-		return Defined{}, false
+	sizes := argSizes(vars)
+	v = function(vm, vars...)
+	// a blocking function returns early when the evaluation is cancelled
+	if err := vm.state.interrupted(); err != nil {
+		vm.state.thrown = nil
+		return ligoNil, err
 	}
-	if v.Type != TypeDFunc {
-		return Defined{}, false
+	if e := vm.state.thrown; e != nil {
+		vm.state.thrown = nil
+		return ligoNil, e
 	}
-	return v.Value.(Defined), true
+	if err := vm.allocResult(v, vars, sizes); err != nil {
+		return ligoNil, err
+	}
+	return v, nil
 }
 
 // RunDefined method is an outlet of the runDefinedFunction function
-func (vm *VM) RunDefined(function Defined, vars []Variable) (Variable, error) {
-	return vm.runDefinedFunction(function, "<defined function call>", vars)
+// A panic in the function is recovered and returned as an exception.
+func (vm *VM) RunDefined(function Defined, vars []Variable) (v Variable, err error) {
+	vm = vm.evaluation()
+	defer vm.enter()()
+	defer vm.recoverPanic("", nil, len(vm.state.frames), &v, &err)
+	name := function.name
+	if name == "" {
+		name = "<defined function call>"
+	}
+	v, err = vm.runDefinedFunction(function, name, Pos{}, vars)
+	if err != nil {
+		return ligoNil, err
+	}
+	return vm.resume(v)
 }
 
-// runDefinedFunction method is a helper method used to run a passed defined function with passed vars
-func (vm *VM) runDefinedFunction(function Defined, fnName string, vars []Variable) (Variable, error) {
+// runDefinedFunction method is a helper method used to run a passed defined function with passed vars.
+// The function body is not evaluated here. It is returned as a tail call to be evaluated by
+// the enclosing eval loop, so that a call in tail position doesn't grow the stack.
+func (vm *VM) runDefinedFunction(function Defined, fnName string, pos Pos, vars []Variable) (Variable, error) {
+	if err := vm.charge(); err != nil {
+		return ligoNil, err
+	}
 	if len(vars) < len(function.scopevars)-1 {
 		return ligoNil, Error(fmt.Sprintf("Expected %d arguments, got %d for the %s function",
 			len(function.scopevars),
@@ -508,58 +778,38 @@ func (vm *VM) runDefinedFunction(function Defined, fnName string, vars []Variabl
 		}
 	}
 
-	nvm := vm.NewScope()
+	env := function.env
+	if env == nil {
+		env = vm
+	}
+	nvm := env.NewScope()
+	nvm.state = vm.state
 	for i, val := range function.scopevars {
 		if len(vars)-1 < i {
 			if isVariate(val) {
-				val = val[3:]
-				nvm.Vars[val] = Variable{Type: TypeArray, Value: make([]Variable, 0)}
+				nvm.bind(val[3:], Variable{Type: TypeArray, Value: make([]Variable, 0)})
 				break
 			}
 			return ligoNil, Error("Not enough arguments to call the function")
 		}
-		switch vars[i].Type {
-		case TypeIFunc:
-			nvm.Funcs[val] = vars[i].Value.(InBuilt)
-		case TypeDFunc:
-			nvm.LFuncs[val] = vars[i].Value.(Defined)
-		default:
-			if isVariate(val) {
-				if len(function.scopevars) != i+1 {
-					panic(fmt.Sprintf("In function %s, the variate parameter should be at the end", fnName))
-				}
-				val = val[3:]
-				if len(vars) >= i+1 {
-					nvm.Vars[val] = Variable{TypeArray, vars[i:]}
-				}
-				break
-			}
-			nvm.Vars[val] = vars[i]
+		if isVariate(val) {
+			nvm.bind(val[3:], Variable{Type: TypeArray, Value: vars[i:]})
+			break
 		}
+		nvm.bind(val, vars[i])
 	}
-	return nvm.Eval(function.eval)
+	return Variable{Type: typeTailCall, Value: &tailCall{vm: nvm, node: function.body, frame: &Frame{Name: fnName, Pos: pos}}}, nil
 }
 
-// run is the method used to call the functions (defined or in-built) with the arguments
-func (vm *VM) run(tkns []string) (Variable, error) {
-	fnName := tkns[0]
-	nspaces := strings.Split(fnName, ".")
-	if len(nspaces) >= 2 {
-		ns, ok := vm.namespaces[nspaces[0]]
-		if ok {
-			ntkns := tkns
-			ntkns[0] = strings.Join(nspaces[1:], ".")
-			return ns.run(ntkns)
-		}
-	}
-
-	vars := make([]Variable, 0)
-	for i := 1; i < len(tkns); i++ {
-
-		if len(tkns[i]) > 3 && tkns[i][:3] == "..." && tkns[i][3] != '.' {
-			v, err := vm.GetVariable(tkns[i][3:])
+// evalArgs method is used to evaluate the argument nodes of a function call.
+// Arguments of the form "...name" are spread if they refer to an array.
+func (vm *VM) evalArgs(tkns []Node) ([]Variable, error) {
+	vars := make([]Variable, 0, len(tkns))
+	for _, tkn := range tkns {
+		if s, ok := tkn.(*Symbol); ok && isVariate(s.Name) {
+			v, err := vm.parseToSymbol(s.Name[3:])
 			if err != nil {
-				return ligoNil, err
+				return nil, err
 			}
 			if v.Type == TypeArray {
 				vars = append(vars, v.Value.([]Variable)...)
@@ -569,111 +819,203 @@ func (vm *VM) run(tkns []string) (Variable, error) {
 			continue
 		}
 
-		v, err := vm.GetVariable(tkns[i])
+		v, err := vm.eval(tkn)
 		if err != nil {
-			return ligoNil, err
+			return nil, err
 		}
 		vars = append(vars, v)
 	}
-	if function, ok := vm.getInBuiltFunction(fnName); ok {
-		return vm.runInBuiltFunction(function, vars)
+	return vars, nil
+}
+
+// run is the method used to call the functions (defined or in-built) with the arguments
+// A macro call is expanded and the resulting code is evaluated in place of it.
+func (vm *VM) run(n *List) (Variable, error) {
+	tkns := n.Nodes
+	head, ok := tkns[0].(*Symbol)
+	if !ok {
+		vars, err := vm.evalArgs(tkns[1:])
+		if err != nil {
+			return ligoNil, err
+		}
+		fn, err := vm.eval(tkns[0])
+		if err != nil {
+			return ligoNil, err
+		}
+		name := "<anonymous>"
+		if fn.Type == TypeDFunc {
+			name = fn.Value.(Defined).name
+		}
+		return vm.callValue(name, tkns[0].Pos(), fn, vars)
 	}
-	if function, ok := vm.getDefinedFunction(fnName); ok {
-		return vm.runDefinedFunction(function, fnName, vars)
+	fn, err := vm.parseToSymbol(head.Name)
+	if err == nil && fn.Type == TypeMacro {
+		code, err := vm.expandCall(n, head.Name, fn.Value.(Macro))
+		if err != nil {
+			return ligoNil, err
+		}
+		return vm.tail(code)
+	}
+	if err == nil && fn.Type == TypeRecordType {
+		return vm.newRecord(fn.Value.(*RecordType), tkns[1:])
 	}
-	if vm.global == nil {
-		return ligoNil, Error("Function '" + fnName + "' not found in any of the namespaces")
+	if err != nil || (fn.Type != TypeIFunc && fn.Type != TypeDFunc) {
+		return ligoNil, Error("Function '" + head.Name + "' not found")
 	}
-	if function, ok := vm.global.getInBuiltFunction(fnName); ok {
-		return vm.runInBuiltFunction(function, vars)
+	vars, err := vm.evalArgs(tkns[1:])
+	if err != nil {
+		return ligoNil, err
 	}
-	if function, ok := vm.global.getDefinedFunction(fnName); ok {
-		return vm.runDefinedFunction(function, fnName, vars)
+	return vm.callValue(head.Name, head.Pos(), fn, vars)
+}
+
+// callValue method is used to run a function value with the passed variables.
+// The call is recorded in the call stack with the passed name and position.
+func (vm *VM) callValue(fnName string, pos Pos, fn Variable, vars []Variable) (Variable, error) {
+	switch fn.Type {
+	case TypeIFunc:
+		if err := vm.pushFrame(fnName, pos); err != nil {
+			return ligoNil, err
+		}
+		defer vm.state.pop()
+		return vm.runInBuiltFunction(fnName, fn.Value.(InBuilt), vars)
+	case TypeDFunc:
+		return vm.runDefinedFunction(fn.Value.(Defined), fnName, pos, vars)
 	}
-	return ligoNil, Error("Function '" + fnName + "' not found")
+	return ligoNil, Error("'" + fnName + "' is not a function, got " + fn.GetTypeString())
 }
 
 // runLoop method is used to run the "loop" construct
-func (vm *VM) runLoop(tkns []string) (Variable, error) {
+func (vm *VM) runLoop(tkns []Node) (Variable, error) {
+	label, tkns := loopLabel(tkns)
 	if len(tkns) != 3 {
 		return ligoNil, Error("Illegal loop construct. Can take 3 arguments only.")
 	}
 	condition := tkns[1]
 	runExp := tkns[2]
-	result, err := vm.Eval(condition)
+	result, err := vm.eval(condition)
 	if err != nil {
 		return ligoNil, err
 	}
 	if result.Type != TypeBool {
-		return ligoNil, Error("Expected boolean return from the expression : " + condition)
+		return ligoNil, Error("Expected boolean return from the expression : " + condition.String())
 	}
 	for result.Value.(bool) {
-		if vm.pc.interrupt {
-			return ligoNil, ErrSignalRecieved
+		if err := vm.state.interrupted(); err != nil {
+			return ligoNil, err
 		}
-		_, err := vm.Eval(runExp)
-		if err != nil {
+		if err := vm.step(); err != nil {
 			return ligoNil, err
 		}
-		result, err = vm.Eval(condition)
+		_, err := vm.eval(runExp)
+		if err != nil {
+			exit, err := loopControl(err, label)
+			if err != nil {
+				return ligoNil, err
+			}
+			if exit {
+				break
+			}
+		}
+		result, err = vm.eval(condition)
 		if err != nil {
 			return ligoNil, err
 		}
 		if result.Type != TypeBool {
-			return ligoNil, Error("Expected boolean return from the expression : " + condition)
+			return ligoNil, Error("Expected boolean return from the expression : " + condition.String())
 		}
 	}
-	return ligoNil, err
+	return ligoNil, nil
 }
 
-// runIn method is used to run the "in" construct
-func (vm *VM) runIn(tkns []string) (Variable, error) {
+// iterator method returns the function giving the items of the passed array,
+// string or channel one after another. ok is false when there are no more items.
+// The items of a channel are received till it is closed.
+func (vm *VM) iterator(v Variable) (func() (Variable, bool, error), error) {
+	switch v.Type {
+	case TypeArray:
+		items, i := v.Value.([]Variable), 0
+		return func() (Variable, bool, error) {
+			if i >= len(items) {
+				return ligoNil, false, nil
+			}
+			i++
+			return items[i-1], true, nil
+		}, nil
+	case TypeString:
+		items, i := []rune(v.Value.(string)), 0
+		return func() (Variable, bool, error) {
+			if i >= len(items) {
+				return ligoNil, false, nil
+			}
+			i++
+			return Variable{Type: TypeString, Value: string(items[i-1])}, true, nil
+		}, nil
+	case TypeChannel:
+		c, err := channelArg("in", v)
+		if err != nil {
+			return nil, err
+		}
+		return func() (Variable, bool, error) {
+			return vm.recv(c)
+		}, nil
+	}
+	return nil, Error("in : can only iterate thorugh arrays, strings or channels")
+}
 
+// runIn method is used to run the "in" construct. The iteration variable is
+// bound in a new scope, visible only inside the loop.
+func (vm *VM) runIn(tkns []Node) (Variable, error) {
+	label, tkns := loopLabel(tkns)
 	if len(tkns) != 4 {
 		return ligoNil, Error("Illegal in loop construct. Can take 4 arguments only.")
 	}
-	iterVar := tkns[2]
+	iterVar := tkns[2].String()
 	runExp := tkns[3]
 
-	array, err := vm.GetVariable(tkns[1])
+	array, err := vm.eval(tkns[1])
 	if err != nil {
 		return ligoNil, err
 	}
 
-	if array.Type != TypeString && array.Type != TypeArray {
-		return ligoNil, Error("in : can only iterate thorugh arrays or strings")
+	next, err := vm.iterator(array)
+	if err != nil {
+		return ligoNil, err
 	}
 
-	v, ok := vm.Vars[iterVar]
-	if array.Type == TypeString {
-		for _, val := range array.Value.(string) {
-			vm.Vars[iterVar] = Variable{Type: TypeString, Value: string(val)}
-			_, err = vm.Eval(runExp)
-			if err != nil {
-				return ligoNil, err
-			}
+	for {
+		val, ok, err := next()
+		if err != nil {
+			return ligoNil, err
 		}
-	} else {
-		for _, val := range array.Value.([]Variable) {
-			vm.Vars[iterVar] = val
-			_, err = vm.Eval(runExp)
+		if !ok {
+			break
+		}
+		if err := vm.step(); err != nil {
+			return ligoNil, err
+		}
+		// every iteration has a scope of it's own, so that the closures made in
+		// the body keep the value of that iteration
+		scope := vm.NewScope()
+		scope.bind(iterVar, val)
+		_, err = scope.eval(runExp)
+		if err != nil {
+			exit, err := loopControl(err, label)
 			if err != nil {
 				return ligoNil, err
 			}
+			if exit {
+				break
+			}
 		}
 	}
-	if ok {
-		vm.Vars[iterVar] = v
-	} else {
-		delete(vm.Vars, iterVar)
-	}
 	return ligoNil, nil
 }
 
 // structEval method is used to evaluate the struct construct
 // and return the corresponding variable
-func (vm *VM) structEval(tkns []string) (Variable, error) {
-	if len(tkns) < 3 && len(tkns)%2 == 0 {
+func (vm *VM) structEval(tkns []Node) (Variable, error) {
+	if len(tkns) < 3 || len(tkns)%2 == 0 {
 		return ligoNil, Error("illegal struct construct. Should take in atleast 3 arguments")
 	}
 
@@ -681,41 +1023,45 @@ func (vm *VM) structEval(tkns []string) (Variable, error) {
 
 	for i := 0; i < (len(tkns) / 2); i++ {
 		index := 1 + (2 * i)
-		key := tkns[index]
-		val, err := vm.GetVariable(tkns[index+1])
+		key := tkns[index].String()
+		val, err := vm.eval(tkns[index+1])
 		if err != nil {
 			return ligoNil, err
 		}
 		mapVar[key] = val
 	}
-	return Variable{Type: TypeStruct, Value: mapVar}, nil
+	strct := Variable{Type: TypeStruct, Value: mapVar}
+	if err := vm.alloc(strct); err != nil {
+		return ligoNil, err
+	}
+	return strct, nil
 }
 
 // matchClause is used to evaluate the match case construct
-func (vm *VM) matchClause(tkns []string) (Variable, error) {
-	if len(tkns) < 4 && len(tkns)%2 != 0 {
+func (vm *VM) matchClause(tkns []Node) (Variable, error) {
+	if len(tkns) < 4 || len(tkns)%2 != 0 {
 		return ligoNil, Error("illegal match construct. Should take in atleast 4 arguments")
 	}
 
-	matchVariable, err := vm.GetVariable(tkns[1])
+	matchVariable, err := vm.eval(tkns[1])
 	if err != nil {
 		return ligoNil, err
 	}
 
 	for i := 1; i <= (len(tkns)/2)-1; i++ {
-		if tkns[2*i] == "_" {
+		if isSymbol(tkns[2*i], "_") {
 			if (2 * i) != len(tkns)-2 {
 				return ligoNil, Error("default case '_' should be placed at last")
 			}
-			return vm.Eval(tkns[(2*i)+1])
+			return vm.tail(tkns[(2*i)+1])
 		}
 
-		caseVariable, err := vm.GetVariable(tkns[2*i])
+		caseVariable, err := vm.eval(tkns[2*i])
 		if err != nil {
 			return ligoNil, err
 		}
-		if caseVariable == matchVariable {
-			return vm.Eval(tkns[(2*i)+1])
+		if Equal(caseVariable, matchVariable) {
+			return vm.tail(tkns[(2*i)+1])
 		}
 	}
 	return ligoNil, nil
@@ -726,274 +1072,456 @@ func (vm *VM) matchClause(tkns []string) (Variable, error) {
 // The if or else clause can be another subexp or can be just a variable.
 // This variable is returned and can be passed directly to functions.
 // See the samples/basic.lg file for more details.
-func (vm *VM) ifClause(tkns []string) (Variable, error) {
+func (vm *VM) ifClause(tkns []Node) (Variable, error) {
 	if len(tkns) > 4 || len(tkns) < 3 {
 		return ligoNil, Error("Illegal if construct. Can take 3 or 4 arguments.")
 	}
 	condition := tkns[1]
-	boolVar, ok := vm.Vars[condition]
-	if condition != "true" && condition != "false" && MatchChars(condition, 0, '(', ')') < 0 && !ok {
+	switch condition.(type) {
+	case *List, *Symbol, *Literal:
+	default:
 		return ligoNil,
-			Error("Expected a boolean value or expression for the if clause condition, got : " + condition)
+			Error("Expected a boolean value or expression for the if clause condition, got : " + condition.String())
 	}
 
-	successClause := tkns[2]
-	failureClause := ""
-	if len(tkns) == 4 {
-		failureClause = tkns[3]
+	ok, err := vm.condition(condition)
+	if err != nil {
+		return ligoNil, err
 	}
-	var result Variable
-	var err error
 	if !ok {
-		result, err = vm.Eval(condition)
-		if err != nil {
-			return ligoNil, err
+		if len(tkns) == 3 {
+			return ligoNil, nil
 		}
-	} else {
-		result = boolVar
+		return vm.tail(tkns[3])
 	}
-	if result.Type != TypeBool {
-		return ligoNil, Error("Expected boolean return from the expression : " + condition)
+	return vm.tail(tkns[2])
+}
+
+// returnArg method is used to return a variable or a value.
+func (vm *VM) returnArg(tkns []Node) (Variable, error) {
+	if len(tkns) > 2 {
+		return ligoNil, Error("Cannot return more than 2 values. (Atleast for now.)")
 	}
-	if !result.Value.(bool) {
-		if failureClause == "" {
-			return ligoNil, nil
+	v := ligoNil
+	if len(tkns) == 2 {
+		var err error
+		v, err = vm.eval(tkns[1])
+		if err != nil {
+			return ligoNil, err
 		}
-		return vm.Eval(failureClause)
 	}
-	return vm.Eval(successClause)
+	return ligoNil, &jump{kind: jumpReturn, value: v, pos: tkns[0].Pos()}
 }
 
-// returnArg method is used to return a variable or a value.
-func (vm *VM) returnArg(tkns []string) (Variable, error) {
-	if len(tkns) != 2 {
-		panic("Cannot return more than 2 values. (Atleast for now.)")
+// breakLoop method is used to stop the enclosing loop, or the loop with the passed label
+func (vm *VM) breakLoop(tkns []Node) (Variable, error) {
+	return vm.loopJump(jumpBreak, tkns)
+}
+
+// continueLoop method is used to skip to the next iteration of the enclosing loop,
+// or the loop with the passed label
+func (vm *VM) continueLoop(tkns []Node) (Variable, error) {
+	return vm.loopJump(jumpContinue, tkns)
+}
+
+// loopJump method is used to create a break or continue jump from the passed arguments
+func (vm *VM) loopJump(kind jumpKind, tkns []Node) (Variable, error) {
+	label, rest := loopLabel(tkns)
+	if len(rest) != 1 {
+		return ligoNil, Error(tkns[0].String() + " : can take only an optional label like :outer")
 	}
-	return vm.GetVariable(tkns[1])
+	return ligoNil, &jump{kind: kind, label: label, pos: tkns[0].Pos()}
 }
 
-// deleteVar method is used to delete a variable from the VM
-func (vm *VM) deleteVar(tkns []string) (Variable, error) {
+// deleteVar method is used to delete the nearest binding of a variable from the VM
+func (vm *VM) deleteVar(tkns []Node) (Variable, error) {
 	if len(tkns) < 2 {
 		return Variable{Type: TypeBool, Value: false}, Error("nothing passed to delete")
 	}
 	for _, variable := range tkns[1:] {
-		_, ok := vm.Vars[variable]
+		_, scope, ok := vm.lookup(variable.String())
 		if !ok {
 			return Variable{Type: TypeBool, Value: false}, Error("variable not found")
 		}
-		delete(vm.Vars, variable)
+		scope.unbind(variable.String())
 	}
 	return Variable{Type: TypeBool, Value: true}, nil
 }
 
-// fork method is used to run the passed sub-expression in a separate go-routine
-func (vm *VM) fork(tkns []string) (Variable, error) {
-	if len(tkns) != 2 {
-		return ligoNil, Error("Expected one expression, got " + fmt.Sprint(len(tkns)) + " arguments")
-	}
-	go vm.Eval(tkns[1])
-	return ligoNil, nil
-}
-
 // namespaceEval method is used to run the code in a namespace environment
-func (vm *VM) namespaceEval(tkns []string) (Variable, error) {
+func (vm *VM) namespaceEval(tkns []Node) (Variable, error) {
 	if len(tkns) < 3 {
 		return ligoNil, Error("Expected atleast 3 expressions, got " + fmt.Sprint(len(tkns)))
 	}
 
-	ns := tkns[1]
-	splitted := strings.Split(ns, ".")
-	nss, ok := vm.namespaces[splitted[0]]
-	if !ok {
-		nss = vm.CreateNamespace(splitted[0])
+	splitted := strings.Split(tkns[1].String(), ".")
+	nss := vm.CreateNamespace(splitted[0])
+	if len(splitted) >= 2 {
+		newTkns := make([]Node, 0, len(tkns))
+		newTkns = append(newTkns, tkns[0], &Symbol{Name: strings.Join(splitted[1:], "."), pos: tkns[1].Pos()})
+		newTkns = append(newTkns, tkns[2:]...)
+		return nss.namespaceEval(newTkns)
 	}
-	if len(splitted) < 2 {
-		for i, val := range tkns[2:] {
-			v, err := nss.Eval(val)
-			if err != nil {
-				return ligoNil, err
-			}
-			if i == len(tkns[2:])-1 {
-				return v, nil
-			}
+
+	v := ligoNil
+	for _, val := range tkns[2:] {
+		var err error
+		v, err = nss.eval(val)
+		if err != nil {
+			return ligoNil, err
 		}
 	}
-	newTkns := make([]string, 0)
-	newTkns = append(newTkns, []string{tkns[0], strings.Join(splitted[2:], ".")}...)
-	for _, val := range newTkns[2:] {
-		newTkns = append(newTkns, val)
-	}
-
-	return nss.namespaceEval(newTkns)
+	return v, nil
 }
 
 // lambdaEval is used to evaluate a lambda expression and return a
 // ligo function
-func (vm *VM) lambdaEval(tkns []string) (Variable, error) {
+func (vm *VM) lambdaEval(tkns []Node) (Variable, error) {
 	if len(tkns) != 3 {
 		return ligoNil, Error("Error in the lambda construct")
 	}
 
-	closure := tkns[1]
-	body := tkns[2]
-
-	if !rClosure.MatchString(tkns[2]) {
-		return ligoNil, Error("malformed closure in the lambda, near " + closure)
+	varNames, err := getVarsFromClosure(tkns[1])
+	if err != nil {
+		return ligoNil, Error("malformed closure in the lambda : " + err.Error())
 	}
-	varNames := getVarsFromClosure(closure)
-	fn := Variable{Type: TypeDFunc, Value: Defined{scopevars: varNames, eval: body}}
+	fn := Variable{Type: TypeDFunc, Value: Defined{name: "lambda", scopevars: varNames, body: tkns[2], env: vm}}
 	return fn, nil
 }
 
 // runExpressions method is used to run the passed sub-expressions
 // Generally this is used inside a loop, function or condition clauses
 // as then can only take one sub-expression for execution.
-func (vm *VM) runExpressions(tkns []string) (Variable, error) {
-	v := ligoNil
-	for i, val := range tkns {
-		if i == 0 || val == "" {
-			continue
-		}
-		vl, err := vm.Eval(val)
+func (vm *VM) runExpressions(tkns []Node) (Variable, error) {
+	if len(tkns) < 2 {
+		return ligoNil, nil
+	}
+	for _, val := range tkns[1 : len(tkns)-1] {
+		_, err := vm.eval(val)
 		if err != nil {
 			return ligoNil, err
 		}
-		if i == len(tkns)-1 {
-			v = vl
-		}
 	}
-	return v, nil
+	return vm.tail(tkns[len(tkns)-1])
 }
 
-// evalString method is used to evaluate a passed string as a ligo expression and
-// pass back it's return
-func (vm *VM) evalString(tkns []string) (Variable, error) {
+// evalString method is used to evaluate a passed string or quoted code as a ligo
+// expression and pass back it's return
+func (vm *VM) evalString(tkns []Node) (Variable, error) {
 	if len(tkns) != 2 {
 		return ligoNil, Error("'eval' keyword only accepts 1 argument")
 	}
-	vl, err := vm.GetVariable(tkns[1])
+	vl, err := vm.eval(tkns[1])
 	if err != nil {
 		return ligoNil, err
 	}
+	if vl.Type == TypeList || vl.Type == TypeSymbol {
+		n, err := NodeOf(vl, tkns[0].Pos())
+		if err != nil {
+			return ligoNil, err
+		}
+		return vm.tail(n)
+	}
 	if vl.Type != TypeString {
-		return ligoNil, Error("'eval' keyword only expression string")
-	}
-	exps := make([]string, 0)
-	line := 0
-	ltxt := vl.Value.(string)
-	for i := 0; i < len(ltxt); i++ {
-		ch := string(ltxt[i])
-		switch ch {
-		case "(":
-			off := MatchChars(ltxt, int64(i), '(', ')') + 1
-			exps = append(exps, ltxt[i:off])
-			i = int(off)
-		case " ", "\n", "\r", "\t":
-			if ch == "\n" || ch == "\r" {
-				line++
-			}
-			continue
-		default:
-			return ligoNil, Error(fmt.Sprintf("Unexpected Character at line %d : %s\n", line, ch))
+		return ligoNil, Error("'eval' keyword only accepts an expression string or quoted code")
+	}
+	// the parsed nodes are evaluated in the current evaluation, so that a return
+	// or break in the string reaches the enclosing function or loop
+	nodes, err := Parse(vl.Value.(string))
+	if err != nil {
+		return ligoNil, err
+	}
+	if len(nodes) < 1 {
+		return ligoNil, Error("Expected atleast a token, got : " + vl.Value.(string))
+	}
+	for _, val := range nodes[:len(nodes)-1] {
+		if _, err := vm.eval(val); err != nil {
+			return ligoNil, err
 		}
 	}
-	var retVal Variable
-	for _, val := range exps {
-		var err error
-		retVal, err = vm.Eval(val)
+	return vm.tail(nodes[len(nodes)-1])
+}
+
+// Eval method is used to parse a passed string and evaluate it.
+// This is the entry point for any proper execution.
+func (vm *VM) Eval(stmt string) (Variable, error) {
+	vm = vm.evaluation()
+	defer vm.enter()()
+	if err := vm.state.interrupted(); err != nil {
+		return ligoNil, err
+	}
+	nodes, err := Parse(stmt)
+	if err != nil {
+		return ligoNil, err
+	}
+	if len(nodes) < 1 {
+		return ligoNil, Error("Expected atleast a token, got : " + stmt)
+	}
+	v := ligoNil
+	for _, val := range nodes {
+		v, err = vm.evalTop(val)
 		if err != nil {
 			return ligoNil, err
 		}
 	}
-	return retVal, nil
+	return v, nil
 }
 
-// catchException method is used to manage the uncaught exception
-func (vm *VM) catchException(tkns []string) (Variable, error) {
-	if len(tkns) != 3 {
-		return ligoNil, Error("catch expects 3 arguments")
-	}
+// EvalContext method is used to evaluate the passed string like Eval, with the
+// evaluation interrupted when the passed context is cancelled or it's deadline
+// is exceeded. The error returned then matches context.Canceled or
+// context.DeadlineExceeded with errors.Is.
+func (vm *VM) EvalContext(ctx context.Context, stmt string) (Variable, error) {
+	return vm.withState(newEvalState(ctx)).Eval(stmt)
+}
 
-	if vm.exception == "" {
-		return ligoNil, nil
-	}
+// Context method returns the context of the current evaluation. The in-built
+// functions which block should return early when it is done.
+func (vm *VM) Context() context.Context {
+	return vm.state.ctx
+}
 
-	scope := vm.Clone()
+// Stop method is used to stop the evaluations of the VM. They return an error
+// matching ErrSignalRecieved, till Resume is called.
+//
+// Deprecated: evaluate with EvalContext (or LoadReaderContext) and cancel the
+// context instead, which also interrupts the functions waiting (like sleep).
+func (vm *VM) Stop() {
+	atomic.StoreInt32(&vm.pc.stopped, 1)
+}
 
-	scope.Vars[tkns[1]] = Variable{Value: vm.exception, Type: TypeString}
-	vm.exception = ""
-	return scope.Eval(tkns[2])
+// Resume method is used to resume the normal evaluation after Stop.
+//
+// Deprecated: evaluate with EvalContext (or LoadReaderContext) instead.
+func (vm *VM) Resume() {
+	atomic.StoreInt32(&vm.pc.stopped, 0)
 }
 
-// Throw method is used to throw an exception in the VM.
-func (vm *VM) Throw(exception string) Variable {
-	vm.exception = exception
-	return ligoNil
+// EvalNode method is used to evaluate an already parsed node.
+// This avoids parsing the source again when the same code is run many times.
+func (vm *VM) EvalNode(n Node) (Variable, error) {
+	vm = vm.evaluation()
+	defer vm.enter()()
+	return vm.evalTop(n)
 }
 
-// Eval method is used to parse a passed string and evaluate it.
-// This is the entry point for any proper execution.
-func (vm *VM) Eval(stmt string) (Variable, error) {
-	if vm.pc.interrupt {
-		return ligoNil, ErrSignalRecieved
-	}
-	stmt = strings.TrimSpace(stmt)
-	if len(stmt) < 1 {
-		return ligoNil, Error("Expected atleast a token, got : " + stmt)
+// evalTop method is used to evaluate a top level node. A return, break or
+// continue which reaches here has no enclosing construct, so it is an error.
+// An exception reaching here is not handled.
+func (vm *VM) evalTop(n Node) (Variable, error) {
+	v, err := vm.evalGuarded([]Node{n})
+	switch e := err.(type) {
+	case *jump:
+		return ligoNil, e.stray(vm.state)
+	case *Exception:
+		return ligoNil, &SourceError{Pos: e.Pos, Err: unhandled{e}, Stack: e.Stack}
+	}
+	return v, err
+}
+
+// evalGuarded method is used to evaluate the nodes like evalBody. A panic in the
+// evaluation (from a keyword or a defined function) is recovered and returned as
+// an exception.
+func (vm *VM) evalGuarded(nodes []Node) (v Variable, err error) {
+	defer vm.recoverPanic("", nil, len(vm.state.frames), &v, &err)
+	return vm.evalBody(nodes)
+}
+
+// evalBody method is used to evaluate a list of nodes one after another.
+// The value of the last one is returned.
+func (vm *VM) evalBody(nodes []Node) (Variable, error) {
+	v := ligoNil
+	for _, n := range nodes {
+		var err error
+		v, err = vm.eval(n)
+		if err != nil {
+			return ligoNil, err
+		}
 	}
-	if !rExpression.MatchString(stmt) && MatchChars(stmt, 0, '(', ')') < 0 {
-		return vm.GetVariable(stmt)
+	return v, nil
+}
+
+// tailBody method is used to evaluate a list of nodes one after another, with
+// the last one in tail position.
+func (vm *VM) tailBody(nodes []Node) (Variable, error) {
+	if len(nodes) == 0 {
+		return ligoNil, nil
 	}
-	tkns, err := ScanTokens(stmt)
-	if err != nil {
+	if _, err := vm.evalBody(nodes[:len(nodes)-1]); err != nil {
 		return ligoNil, err
 	}
-	if len(tkns) < 1 {
-		return ligoNil, nil
+	return vm.tail(nodes[len(nodes)-1])
+}
+
+// eval method is used to evaluate a node of the syntax tree.
+// Any error returned carries the position of the node where it occurred.
+func (vm *VM) eval(n Node) (Variable, error) {
+	return vm.trampoline(n, nil)
+}
+
+// trampoline method is the eval loop. The constructs return the node in tail
+// position (last expression of progn, branches of if and match, function bodies)
+// as a tail call, which is evaluated here in a loop instead of recursing. So a
+// chain of tail calls runs in constant stack. The call frame of a function called
+// in tail position replaces the frame of the function which called it.
+func (vm *VM) trampoline(n Node, frame *Frame) (Variable, error) {
+	scope := vm
+	framed := false
+	for {
+		if frame != nil {
+			if framed {
+				vm.state.frames[len(vm.state.frames)-1] = *frame
+			} else {
+				if err := vm.pushFrame(frame.Name, frame.Pos); err != nil {
+					return ligoNil, err
+				}
+				framed = true
+			}
+		}
+		v, err := scope.evalNode(n)
+		if err != nil {
+			err = scope.errorAt(n, err)
+			if framed {
+				if j, ok := err.(*jump); ok {
+					if j.kind == jumpReturn {
+						vm.state.pop()
+						return j.value, nil
+					}
+					err = j.stray(vm.state)
+				}
+				vm.state.pop()
+			}
+			return ligoNil, err
+		}
+		if v.Type != typeTailCall {
+			if framed {
+				vm.state.pop()
+			}
+			return v, nil
+		}
+		tc := v.Value.(*tailCall)
+		scope, n, frame = tc.vm, tc.node, tc.frame
 	}
-	fnName := tkns[0]
+}
 
-	if vm.exception != "" && fnName != "catch" {
-		return ligoNil, ErrExceptionNotHandled
+// resume method is used to evaluate a pending tail call returned by a construct
+// or a defined function. Other values are returned as they are.
+func (vm *VM) resume(v Variable) (Variable, error) {
+	if v.Type != typeTailCall {
+		return v, nil
+	}
+	tc := v.Value.(*tailCall)
+	return tc.vm.trampoline(tc.node, tc.frame)
+}
+
+// tail method is used to return a node in tail position, to be evaluated by
+// the enclosing eval loop in the passed VM's scope.
+func (vm *VM) tail(n Node) (Variable, error) {
+	return Variable{Type: typeTailCall, Value: &tailCall{vm: vm, node: n}}, nil
+}
+
+// evalNode method is used to evaluate a node based on it's kind
+func (vm *VM) evalNode(n Node) (Variable, error) {
+	select {
+	case <-vm.state.done:
+		return ligoNil, vm.state.interrupted()
+	default:
+	}
+	if atomic.LoadInt32(&vm.pc.stopped) != 0 {
+		return ligoNil, &cancelError{err: ErrSignalRecieved, cause: context.Canceled}
 	}
+	if err := vm.step(); err != nil {
+		return ligoNil, err
+	}
+	switch n := n.(type) {
+	case *Literal:
+		return n.Value, nil
+	case *Symbol:
+		return vm.parseToSymbol(n.Name)
+	case *Array:
+		return vm.evalArray(n)
+	case *List:
+		return vm.evalList(n)
+	}
+	return ligoNil, Error("Unexpected token found : " + n.String())
+}
 
-	if fnName == "catch" {
-		return vm.catchException(tkns)
+// evalList method is used to evaluate a sub expression. The first node of the
+// list is either a keyword or the function to be called.
+func (vm *VM) evalList(n *List) (Variable, error) {
+	if len(n.Nodes) < 1 {
+		return ligoNil, nil
 	}
-	return vm.evalKeyword(fnName, tkns)
+	return vm.evalKeyword(n)
 }
 
 // evalKeyword is used to run the corresponding function for the given keyword
-func (vm *VM) evalKeyword(fnName string, tkns []string) (Variable, error) {
-	handler, ok := vm.keywordHandler[fnName]
-	if ok {
-		return handler(tkns)
+func (vm *VM) evalKeyword(n *List) (Variable, error) {
+	if s, ok := n.Nodes[0].(*Symbol); ok {
+		handler, ok := keywordHandler[s.Name]
+		if ok {
+			return vm.runKeyword(s.Name, handler, n)
+		}
 	}
-	return vm.run(tkns)
+	return vm.run(n)
+}
+
+// runKeyword method is used to run the handler of a keyword. A panic in it is
+// recovered and returned as an exception at the position of the keyword call.
+func (vm *VM) runKeyword(name string, handler keyword, n *List) (v Variable, err error) {
+	defer vm.recoverPanic(name, n, len(vm.state.frames), &v, &err)
+	return handler(vm, n.Nodes)
 }
 
 // GetNameSpace method is used to get the namespace scope corresponding to the name passed
 func (vm *VM) GetNameSpace(ns string) *VM {
+	vm.mu.RLock()
 	namespace, ok := vm.namespaces[ns]
+	vm.mu.RUnlock()
 	if !ok {
 		return nil
 	}
-	return namespace
+	return namespace.withState(vm.state)
 }
 
 // CreateNamespace method is used to create a new namespace if it doesn't exist
 func (vm *VM) CreateNamespace(ns string) *VM {
-	if namespace := vm.GetNameSpace(ns); namespace != nil {
-		return namespace
+	vm.mu.Lock()
+	namespace, ok := vm.namespaces[ns]
+	if !ok {
+		namespace = vm.NewScope()
+		vm.namespaces[ns] = namespace
+	}
+	vm.mu.Unlock()
+	return namespace.withState(vm.state)
+}
+
+// LoadPlugin method is used to run the init function of a plugin (PluginInit)
+// and add the definitions made by it to the scope of the VM. Unlike calling the
+// init function on the VM directly, it can be used while the VM is being
+// evaluated in other goroutines.
+func (vm *VM) LoadPlugin(init func(*VM)) {
+	staged := vm.NewScope()
+	init(staged)
+	staged.mu.RLock()
+	defer staged.mu.RUnlock()
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	for key, value := range staged.Funcs {
+		vm.store(key, Variable{Type: TypeIFunc, Value: value})
+	}
+	for key, value := range staged.LFuncs {
+		vm.store(key, Variable{Type: TypeDFunc, Value: value})
+	}
+	for key, value := range staged.Vars {
+		vm.store(key, value)
 	}
-	vm.namespaces[ns] = vm.NewScope()
-	vm.namespaces[ns].isNamespace = true
-	return vm.namespaces[ns]
 }
 
 // Clone method is used to clone the VM and return the clone one.
 func (vm *VM) Clone() *VM {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
 	nvm := NewVM()
 	for key, value := range vm.Funcs {
 		nvm.Funcs[key] = value
@@ -1007,75 +1535,92 @@ func (vm *VM) Clone() *VM {
 	return nvm
 }
 
-// NewScope method is used to create a new vm with global scope set from the
-// current VM.
-// (if the current vm is the parent vm, then it is set as the global, else the global of the current vm is set )
+// NewScope method is used to create a new vm with the current VM as the parent scope.
+// Variables defined in the new scope are not visible to the current VM, but the
+// variables of the current VM (and it's parents) are visible in the new scope.
 func (vm *VM) NewScope() *VM {
-	nvm := NewVM()
-	if vm.global == nil || vm.isNamespace {
-		nvm.global = vm
-	} else {
-		nvm.global = vm.global
+	return &VM{
+		parent:     vm,
+		Vars:       make(map[string]Variable),
+		Funcs:      make(map[string]InBuilt),
+		LFuncs:     make(map[string]Defined),
+		namespaces: make(map[string]*VM),
+		mu:         &sync.RWMutex{},
+		pc:         vm.pc,
+		state:      vm.state,
 	}
-	nvm.pc = vm.pc
-	return nvm
 }
 
-// LoadReader method is used to load script from a io.Reader and evaluate it
+// evaluation method returns the VM to run an evaluation started from go. If it is
+// not nested in an evaluation (like one called by an in-built function) it gets
+// a new evaluation state, so that the evaluations run in different goroutines
+// don't share their call stacks.
+func (vm *VM) evaluation() *VM {
+	if vm.state.active == 0 {
+		return vm.withState(newEvalState(vm.state.ctx))
+	}
+	return vm
+}
+
+// Detach method returns the VM to be used by an in-built function evaluating from
+// a goroutine of it's own (with Eval, EvalNode or RunDefined), like a callback run
+// by a go library. The evaluations of the VM passed to the function share it's call
+// stack, so they are not safe to run from another goroutine. The returned VM has a
+// call stack of it's own, and it is still interrupted with the current evaluation
+// and counts it's work in the budget of it. It should be called before the goroutine
+// is started.
+func (vm *VM) Detach() *VM {
+	st := newEvalState(vm.state.ctx)
+	st.meter, st.active = vm.state.meter, 1
+	return vm.withState(st)
+}
+
+// withState method returns a copy of the VM sharing the same scope but
+// evaluating with the passed evaluation state.
+func (vm *VM) withState(st *evalState) *VM {
+	nvm := *vm
+	nvm.state = st
+	return &nvm
+}
+
+// LoadReaderContext method is used to load the ligo source from the reader like
+// LoadReader, with the evaluation interrupted when the passed context is done.
+func (vm *VM) LoadReaderContext(ctx context.Context, input io.Reader) error {
+	return vm.withState(newEvalState(ctx)).LoadReader(input)
+}
+
+// LoadReader method is used to load script from a io.Reader and evaluate it.
+// If the reader has a name (like *os.File), it is used as the file name in
+// the positions of the returned errors.
 func (vm *VM) LoadReader(input io.Reader) error {
 	ltxtb, err := ioutil.ReadAll(input)
 	if err != nil {
 		return err
 	}
 
-	exps, err := vm.BreakChunk(string(ltxtb))
+	file := ""
+	if named, ok := input.(interface{ Name() string }); ok {
+		file = named.Name()
+	}
+
+	exps, err := ParseFile(file, string(ltxtb))
 	if err != nil {
 		return err
 	}
 
+	vm = vm.evaluation()
+	defer vm.enter()()
 	for _, val := range exps {
-		_, err := vm.Eval(val)
+		_, err := vm.evalTop(val)
 		if err != nil {
-			return fmt.Errorf("error : %s", err)
+			return err
 		}
 	}
 
 	return nil
 }
 
-// BreakChunk is used to break a chunk of ligo code into string list of subexps
-func (vm VM) BreakChunk(ltxt string) ([]string, error) {
-	ltxt = StripComments(ltxt)
-	exps := make([]string, 0)
-	line := 0
-	inComment := false
-	for i := 0; i < len(ltxt); i++ {
-		ch := string(ltxt[i])
-		switch ch {
-		case "(":
-			if inComment {
-				continue
-			}
-			off := MatchChars(ltxt, int64(i), '(', ')') + 1
-			if off < int64(i) {
-				return nil, fmt.Errorf("Syntax error near %d:%d : %s", i, line, ltxt[i:])
-			}
-			exps = append(exps, ltxt[i:off])
-			i = int(off) - 1
-		case " ", "\n", "\r", "\t":
-			if ch == "\n" || ch == "\r" {
-				line++
-				inComment = false
-			}
-			continue
-		case ";":
-			inComment = true
-		default:
-			if inComment {
-				continue
-			}
-			return nil, fmt.Errorf("unexpected Character at line %d : %s", line, ch)
-		}
-	}
-	return exps, nil
+// BreakChunk is used to break a chunk of ligo code into the list of top level nodes
+func (vm *VM) BreakChunk(ltxt string) ([]Node, error) {
+	return Parse(ltxt)
 }
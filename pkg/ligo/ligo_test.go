@@ -0,0 +1,872 @@
+package ligo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestVM function returns a VM with the few in-built functions used by the
+// tests, as the usual ones come from the packages loaded by the host.
+func newTestVM(t *testing.T) *VM {
+	t.Helper()
+	vm := NewVM()
+	vm.Funcs["+"] = testAdd
+	vm.Funcs["-"] = func(vm *VM, a ...Variable) Variable {
+		return Variable{Type: TypeInt, Value: a[0].Value.(int64) - a[1].Value.(int64)}
+	}
+	vm.Funcs["<"] = func(vm *VM, a ...Variable) Variable {
+		return Variable{Type: TypeBool, Value: a[0].Value.(int64) < a[1].Value.(int64)}
+	}
+	vm.Funcs["=="] = func(vm *VM, a ...Variable) Variable {
+		return Variable{Type: TypeBool, Value: Equal(a[0], a[1])}
+	}
+	vm.Funcs["push"] = func(vm *VM, a ...Variable) Variable {
+		items := append([]Variable{}, a[0].Value.([]Variable)...)
+		return Variable{Type: TypeArray, Value: append(items, a[1:]...)}
+	}
+	vm.Funcs["throw"] = func(vm *VM, a ...Variable) Variable {
+		if len(a) == 1 {
+			return vm.Throw(fmt.Sprint(a[0].Value))
+		}
+		payload := ligoNil
+		if len(a) == 3 {
+			payload = a[2]
+		}
+		return vm.ThrowException(a[0].Value.(string), fmt.Sprint(a[1].Value), payload)
+	}
+	vm.Funcs["type"] = func(vm *VM, a ...Variable) Variable {
+		return Variable{Type: TypeString, Value: a[0].GetTypeString()}
+	}
+	// depth returns the number of function calls in the call stack
+	vm.Funcs["depth"] = func(vm *VM, a ...Variable) Variable {
+		return Variable{Type: TypeInt, Value: int64(len(vm.state.frames))}
+	}
+	vm.SetMaxCallDepth(200)
+	return vm
+}
+
+// testAdd function is the + of the test VMs, adding the integers passed
+func testAdd(vm *VM, a ...Variable) Variable {
+	sum := int64(0)
+	for _, v := range a {
+		sum += v.Value.(int64)
+	}
+	return Variable{Type: TypeInt, Value: sum}
+}
+
+// source function returns the value in the form it is written in the source,
+// so that the results can be compared as strings
+func source(t *testing.T, v Variable) string {
+	t.Helper()
+	n, err := NodeOf(v, Pos{})
+	if err != nil {
+		t.Fatalf("NodeOf(%v) : %v", v, err)
+	}
+	return n.String()
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+		// err is a part of the error expected, if any
+		err string
+	}{
+		{
+			name: "set a global in a function",
+			code: `(var n 0) (fn inc || (set n (+ n 1))) (inc) (inc) n`,
+			want: "2",
+		},
+		{
+			name: "set a variable of the enclosing function",
+			code: `(fn outer || (progn (var n 1) (fn bump || (set n (+ n 10))) (bump) n)) (outer)`,
+			want: "11",
+		},
+		{
+			name: "set a namespace variable",
+			code: `(namespace ns (var c 1) (fn bump || (set c (+ c 1)))) (ns.bump) (ns.bump) ns.c`,
+			want: "3",
+		},
+		{
+			name: "set a variable not defined",
+			code: `(fn f || (var local 1)) (f) (set local 2)`,
+			err:  "Variable 'local' not defined",
+		},
+
+		// a call in tail position doesn't grow the stack (the depth is 200), only
+		// the frames of count and depth are in it
+		{
+			name: "tail call",
+			code: `(fn count |n acc| (if (== n 0) acc (count (- n 1) (+ acc 1)))) (count 10000 0)`,
+			want: "10000",
+		},
+		{
+			name: "depth of tail calls",
+			code: `(fn count |n| (if (== n 0) (depth) (count (- n 1)))) (count 100)`,
+			want: "2",
+		},
+		{
+			name: "mutual tail calls",
+			code: `(fn even |n| (if (== n 0) true (odd (- n 1))))
+			       (fn odd |n| (if (== n 0) false (even (- n 1))))
+			       (even 10001)`,
+			want: "false",
+		},
+		{
+			name: "tail call in progn",
+			code: `(fn count |n| (progn (var m (- n 1)) (if (< m 0) (depth) (count m)))) (count 100)`,
+			want: "2",
+		},
+
+		{
+			name: "return leaves the function",
+			code: `(fn first-big |xs| (progn (in xs x (if (< 2 x) (return x))) 0)) (first-big [1 5 7])`,
+			want: "5",
+		},
+		{
+			name: "labelled continue and break",
+			code: `(var out [])
+			       (in :outer [1 2 3] a
+			           (in [1 2 3] b
+			               (progn
+			                 (if (== a b) (continue :outer))
+			                 (if (== (+ a b) 4) (break :outer))
+			                 (set out (push out a b)))))
+			       out`,
+			want: "[2 1]",
+		},
+		{
+			name: "break of the outer loop",
+			code: `(var i 0)
+			       (loop :outer (< i 10)
+			           (progn
+			             (set i (+ i 1))
+			             (in [1 2] b (if (== i 3) (break :outer)))))
+			       i`,
+			want: "3",
+		},
+		{
+			name: "continue",
+			code: `(var out []) (in [1 2 3 4] a (progn (if (== a 2) (continue)) (set out (push out a)))) out`,
+			want: "[1 3 4]",
+		},
+		{
+			name: "unknown label",
+			code: `(in [1 2] a (break :outer))`,
+			err:  ":outer",
+		},
+		{
+			name: "break outside a loop",
+			code: `(break)`,
+			err:  "break",
+		},
+		{
+			name: "return in eval",
+			code: `(fn f |x| (progn (eval "(return (+ x 1))") 0)) (f 1)`,
+			want: "2",
+		},
+		{
+			name: "break in eval",
+			code: `(var n 0) (loop true (progn (set n (+ n 1)) (eval "(if (== n 3) (break))"))) n`,
+			want: "3",
+		},
+		{
+			name: "return in eval outside a function",
+			code: `(eval "(return 1)")`,
+			err:  "return : used outside of a function",
+		},
+
+		{
+			name: "catch by kind",
+			code: `(try (throw "age" "too young" 12) (catch "io" e 1) (catch "age" e e:Payload))`,
+			want: "12",
+		},
+		{
+			name: "catch any kind",
+			code: `(try (throw "age" "too young" 12) (catch "io" e 1) (catch e e:Message))`,
+			want: `"too young"`,
+		},
+		{
+			name: "finally",
+			code: `(var log [])
+			       (try (throw "oops") (catch e (set log (push log 1))) (finally (set log (push log 2))))
+			       log`,
+			want: "[1 2]",
+		},
+		{
+			name: "finally of an uncaught exception",
+			code: `(var log [])
+			       (try (try (throw "io" "oops") (catch "age" e 1) (finally (set log (push log 2))))
+			            (catch e (set log (push log e:Kind))))
+			       log`,
+			want: `[2 "io"]`,
+		},
+		{
+			name: "rethrow",
+			code: `(try (try (throw "oops") (catch e (rethrow e))) (catch e e:Message))`,
+			want: `"oops"`,
+		},
+		{
+			name: "catch an error of the interpreter",
+			code: `(try (nofn 1) (catch e e:Message))`,
+			want: `"Function 'nofn' not found"`,
+		},
+		{
+			name: "break is not caught",
+			code: `(var n 0) (loop (< n 5) (progn (set n (+ n 1)) (try (break) (catch e (set n 100))))) n`,
+			want: "1",
+		},
+		{
+			name: "uncaught exception",
+			code: `(try (throw "io" "oops") (catch "age" e 1))`,
+			err:  "io : oops",
+		},
+
+		{
+			name: "stack overflow",
+			code: `(fn deep |n| (+ 1 (deep n))) (try (deep 1) (catch "stack overflow" e e:Kind))`,
+			want: `"stack overflow"`,
+		},
+		{
+			name: "usable after a stack overflow",
+			code: `(fn deep |n| (+ 1 (deep n))) (try (deep 1) (catch e 0)) (deep 0)`,
+			err:  "stack overflow",
+		},
+
+		{
+			name: "quote",
+			code: "'(a b (c 1))",
+			want: "(a b (c 1))",
+		},
+		{
+			name: "quasiquote",
+			code: "`(a ,(+ 1 2) ,@[4 5])",
+			want: "(a 3 4 5)",
+		},
+		{
+			name: "splice nothing",
+			code: "`(a ,@[] b)",
+			want: "(a b)",
+		},
+		{
+			name: "splice in an array",
+			code: "`[1 ,@[2 3] 4]",
+			want: "[1 2 3 4]",
+		},
+		{
+			name: "splice a list",
+			code: "(var xs '(b c)) `(a ,@xs d)",
+			want: "(a b c d)",
+		},
+		{
+			name: "splice in a macro",
+			code: "(defmacro my-unless |c ...body| `(if ,c () (progn ,@body))) (my-unless false 1 2)",
+			want: "2",
+		},
+		{
+			name: "splice outside a quasiquote",
+			code: "(unquote-splicing [1])",
+			err:  "quasiquote",
+		},
+
+		{
+			name: "let",
+			code: `(var a 10) (let ((a 1) (b (+ a 1))) (+ a b))`,
+			want: "12",
+		},
+		{
+			name: "let*",
+			code: `(let* ((a 1) (b (+ a 1))) (+ a b))`,
+			want: "3",
+		},
+		{
+			name: "letrec",
+			code: `(letrec ((even (lambda |n| (if (== n 0) true (odd (- n 1)))))
+			           (odd (lambda |n| (if (== n 0) false (even (- n 1))))))
+			       (even 10))`,
+			want: "true",
+		},
+		{
+			name: "let is scoped",
+			code: `(let ((a 1)) a) a`,
+			err:  "not found in scope : a",
+		},
+		{
+			name: "tail call in let",
+			code: `(fn count |n| (let ((m (- n 1))) (if (< m 0) n (count m)))) (count 5000)`,
+			want: "0",
+		},
+		{
+			name: "var in the body of in",
+			code: `(var out []) (in [1 2 3] x (progn (var y (+ x 10)) (set out (push out y)))) out`,
+			want: "[11 12 13]",
+		},
+		{
+			name: "closures made in in",
+			code: `(var fs []) (in [1 2] x (set fs (push fs (lambda || x))))
+			       (var out []) (in fs f (set out (push out (f)))) out`,
+			want: "[1 2]",
+		},
+
+		{
+			name: "and short-circuits",
+			code: `(and true false (nofn))`,
+			want: "false",
+		},
+		{
+			name: "or short-circuits",
+			code: `(or false true (nofn))`,
+			want: "true",
+		},
+		{
+			name: "cond",
+			code: `(cond ((== 1 2) 1) ((== 1 1) 2) (else 3))`,
+			want: "2",
+		},
+		{
+			name: "when and unless",
+			code: `(push [] (when true 1 2) (unless true 1))`,
+			want: "[2 nil]",
+		},
+		{
+			name: "mutual tail calls in cond",
+			code: `(fn even |n| (cond ((== n 0) true) (else (odd (- n 1)))))
+			       (fn odd |n| (cond ((== n 0) false) (else (even (- n 1)))))
+			       (even 10001)`,
+			want: "false",
+		},
+
+		{
+			name: "set a member",
+			code: `(var p (struct Name "Jo" Age 20)) (set p:Name "Al") (push [] p:Name p:Age)`,
+			want: `["Al" 20]`,
+		},
+		{
+			name: "set a nested member",
+			code: `(var p (struct Name "Jo" Address (struct City "X"))) (set p:Address:City "Pune") p:Address:City`,
+			want: `"Pune"`,
+		},
+		{
+			name: "set a bad path",
+			code: `(var p (struct Name "Jo")) (set p:Address:City "Pune")`,
+			err:  `no such key found in the struct : "Address:City"`,
+		},
+		{
+			name: "set a member of a value not a struct",
+			code: `(var p 1) (set p:Name 2)`,
+			err:  "passed variable is not a struct",
+		},
+		{
+			name: "struct-with",
+			code: `(var p (struct Name "Jo" Address (struct City "X")))
+			       (var q (struct-with p Name "Al" Address:City "Pune"))
+			       (push [] p:Name p:Address:City q:Name q:Address:City)`,
+			want: `["Jo" "X" "Al" "Pune"]`,
+		},
+
+		{
+			name: "record",
+			code: `(defstruct person Name Age) (var p (person Name "Jo" Age 20)) (push [] p:Name p:Age)`,
+			want: `["Jo" 20]`,
+		},
+		{
+			name: "record predicate",
+			code: `(defstruct person Name Age)
+			       (push [] (person? (person Name "Jo" Age 20)) (person? (struct Name 1 Age 2)) (person? 1))`,
+			want: "[true false false]",
+		},
+		{
+			name: "record accessor",
+			code: `(defstruct person Name Age) (person-Age (person Name "Jo" Age 20))`,
+			want: "20",
+		},
+		{
+			name: "record accessor of a value not a record",
+			code: `(defstruct person Name Age) (person-Name 1)`,
+			err:  "person-Name : expected a person",
+		},
+		{
+			name: "type of a record",
+			code: `(deftype person Name Age) (type (person Name "Jo" Age 20))`,
+			want: `"person"`,
+		},
+		{
+			name: "record with a missing field",
+			code: `(defstruct person Name Age) (person Name "Jo")`,
+			err:  "person : missing field Age",
+		},
+		{
+			name: "record with an unknown field",
+			code: `(defstruct person Name Age) (person Name "Jo" Age 1 Height 2)`,
+			err:  "person : unknown field Height",
+		},
+		{
+			name: "set a field of a record",
+			code: `(defstruct person Name Age) (var p (person Name "Jo" Age 20)) (set p:Age 21) p:Age`,
+			want: "21",
+		},
+		{
+			name: "set an unknown field of a record",
+			code: `(defstruct person Name Age) (var p (person Name "Jo" Age 20)) (set p:Height 2)`,
+			err:  `no such key found in the struct : "Height"`,
+		},
+		{
+			name: "struct-with of a record",
+			code: `(defstruct person Name Age) (var p (person Name "Jo" Age 20)) (var q (struct-with p Age 30))
+			       (push [] p:Age q:Age (person? q) (type q))`,
+			want: `[20 30 true "person"]`,
+		},
+
+		{
+			name: "join",
+			code: `(join (fork (+ 1 2)))`,
+			want: "3",
+		},
+		{
+			name: "join-all",
+			code: `(join-all [(fork 1) (fork 2)])`,
+			want: "[1 2]",
+		},
+		{
+			name: "join-any",
+			code: `(join-any [(fork 7)])`,
+			want: "7",
+		},
+		{
+			name: "done?",
+			code: `(var t (fork 1)) (join t) (done? t)`,
+			want: "true",
+		},
+		{
+			name: "cancel",
+			code: `(var t (fork (loop true 1))) (cancel t) (try (join t) (catch "cancelled" e e:Kind))`,
+			want: `"cancelled"`,
+		},
+		{
+			name: "join as a value",
+			code: `(var j join) (fn apply |f x| (f x)) (apply j (fork (+ 1 2)))`,
+			want: "3",
+		},
+		{
+			name: "function named join",
+			code: `(fn join |a b| (+ a b)) (join 1 2)`,
+			want: "3",
+		},
+		{
+			name: "join of a task throwing",
+			code: `(try (join (fork (throw "io" "bad"))) (catch "io" e e:Message))`,
+			want: `"bad"`,
+		},
+
+		{
+			name: "channel",
+			code: `(var c (chan-new)) (fork (chan-send c 5)) (chan-recv c)`,
+			want: "5",
+		},
+		{
+			name: "in over a channel",
+			code: `(var c (chan-new 2)) (chan-send c 1) (chan-send c 2) (chan-close c)
+			       (var out []) (in c v (set out (push out v))) out`,
+			want: "[1 2]",
+		},
+		{
+			name: "send on a closed channel",
+			code: `(var c (chan-new 1)) (chan-close c) (chan-send c 1)`,
+			err:  "closed channel",
+		},
+		{
+			name: "select",
+			code: `(var c (chan-new 1)) (chan-send c 4) (select ((chan-recv c r) (+ r 1)) ((timeout 1000) 0))`,
+			want: "5",
+		},
+		{
+			name: "select timeout",
+			code: `(var c (chan-new)) (select ((chan-recv c r) r) ((timeout 10) "late"))`,
+			want: `"late"`,
+		},
+		{
+			name: "select default",
+			code: `(var c (chan-new)) (select ((chan-recv c r) r) (default "empty"))`,
+			want: `"empty"`,
+		},
+		{
+			name: "channel functions as values",
+			code: `(var c (chan-new 2)) (var put chan-send) (put c 1) (chan-close c) (var get chan-recv) (push [] (get c) (get c))`,
+			want: "[1 nil]",
+		},
+
+		{
+			name: "join an actor",
+			code: `(join (spawn-vm (+ 1 2)))`,
+			want: "3",
+		},
+		{
+			name: "actor has a copy of the variables",
+			code: `(var x 1) (join (spawn-vm (set x 2))) x`,
+			want: "1",
+		},
+		{
+			name: "send and receive",
+			code: `(var a (spawn-vm (send (receive) 9))) (send a (self)) (receive 1000)`,
+			want: "9",
+		},
+		{
+			name: "receive timeout",
+			code: `(receive 10)`,
+			want: "nil",
+		},
+		{
+			name: "supervision",
+			code: `(spawn-vm (throw "io" "bad")) (var m (receive 1000)) m:Exception:Kind`,
+			want: `"io"`,
+		},
+		{
+			name: "actor functions as values",
+			code: `(var me self) (var post send) (post (me) 5) (var get receive) (get 100)`,
+			want: "5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := newTestVM(t).Eval(tt.code)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected an error with %q, got %v (value %v)", tt.err, err, v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error : %v", err)
+			}
+			if got := source(t, v); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// evalFile function evaluates the passed source as if it is read from the
+// passed file and returns the error of the first node failing
+func evalFile(t *testing.T, file, code string) error {
+	t.Helper()
+	nodes, err := ParseFile(file, code)
+	if err != nil {
+		return err
+	}
+	vm := newTestVM(t)
+	for _, n := range nodes {
+		if _, err := vm.EvalNode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "function not found",
+			code: "(var a 1)\n  (nofn a)",
+			want: "main.lg:2:3: Function 'nofn' not found",
+		},
+		{
+			name: "variable not found",
+			code: "(+ 1\n   b)",
+			want: "main.lg:2:4: Variable not found in scope : b",
+		},
+		{
+			name: "error inside a function",
+			code: "(fn f |x| (+ x y))\n(f 1)",
+			want: "main.lg:1:16: Variable not found in scope : y",
+		},
+		{
+			name: "syntax error",
+			code: "(var a 1)\n(var b (+ a 1)",
+			want: "main.lg:2:1: Syntax Error : '(' not closed correctly",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := evalFile(t, "main.lg", tt.code)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("got %v, want %s", err, tt.want)
+			}
+		})
+	}
+
+	// the position is in the error returned by Eval too, without a file name
+	_, err := newTestVM(t).Eval("(var a 1)\n  (nofn a)")
+	var se *SourceError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a *SourceError, got %#v", err)
+	}
+	if se.Pos != (Pos{Line: 2, Column: 3}) || se.Pos.String() != "2:3" {
+		t.Errorf("got the position %#v, want 2:3", se.Pos)
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want StackTrace
+	}{
+		{
+			name: "nested functions",
+			code: "(fn inner |x| (+ 1 (nofn x)))\n(fn outer |x| (+ 1 (inner x)))\n(outer 1)",
+			want: StackTrace{
+				{Name: "inner", Pos: Pos{File: "main.lg", Line: 2, Column: 21}},
+				{Name: "outer", Pos: Pos{File: "main.lg", Line: 3, Column: 2}},
+			},
+		},
+		{
+			name: "uncaught exception",
+			code: "(namespace ns (fn f |x| (+ 1 (throw \"io\" \"bad\"))))\n(+ 1 (ns.f 1))",
+			want: StackTrace{
+				{Name: "throw", Pos: Pos{File: "main.lg", Line: 1, Column: 31}},
+				{Name: "ns.f", Pos: Pos{File: "main.lg", Line: 2, Column: 7}},
+			},
+		},
+		{
+			name: "lambda",
+			code: "(var l (lambda |x| (+ x y)))\n(l 1)",
+			want: StackTrace{
+				{Name: "l", Pos: Pos{File: "main.lg", Line: 2, Column: 2}},
+			},
+		},
+		{
+			name: "top level",
+			code: "(nofn 1)",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetStackTrace(evalFile(t, "main.lg", tt.code))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got the stack trace\n%swant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	want := "\tat inner (main.lg:2:21)\n\tat outer (main.lg:3:2)\n"
+	if got := tests[0].want.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if GetStackTrace(errors.New("plain")) != nil {
+		t.Error("expected no stack trace for an error not returned by the VM")
+	}
+}
+
+func TestUncaughtException(t *testing.T) {
+	_, err := newTestVM(t).Eval(`(throw "oops")`)
+	if !errors.Is(err, ErrExceptionNotHandled) {
+		t.Fatalf("expected ErrExceptionNotHandled, got %v", err)
+	}
+	var e *Exception
+	if !errors.As(err, &e) || e.Message != "oops" {
+		t.Fatalf("expected the exception oops, got %v", err)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	vm := newTestVM(t)
+	vm.Funcs["boom"] = func(vm *VM, a ...Variable) Variable {
+		var m map[string]int
+		m["a"] = 1
+		return ligoNil
+	}
+	v, err := vm.Eval(`(try (boom) (catch "panic" e e))`)
+	if err != nil {
+		t.Fatalf("unexpected error : %v", err)
+	}
+	e, ok := v.Value.(*Exception)
+	if !ok {
+		t.Fatalf("expected an exception, got %v", v)
+	}
+	if e.Kind != PanicExceptionKind || !strings.HasPrefix(e.Message, "boom : assignment to entry in nil map") {
+		t.Errorf("got the exception %s : %s", e.Kind, e.Message)
+	}
+	if !strings.Contains(e.GoStack, "goroutine") {
+		t.Errorf("expected the go stack in the exception, got %q", e.GoStack)
+	}
+
+	// the go stack is a field of the exception in the script too
+	v, err = vm.Eval(`(try (boom) (catch e (== e:GoStack "")))`)
+	if err != nil || v.Value != false {
+		t.Errorf("got %v, %v, want the go stack", v, err)
+	}
+
+	// an uncaught panic is returned as an error and the VM is still usable
+	_, err = vm.Eval(`(fn f || (+ 1 (boom))) (f)`)
+	if !errors.As(err, &e) || e.Kind != PanicExceptionKind || GetStackTrace(err) == nil {
+		t.Fatalf("expected the panic with a stack trace, got %v", err)
+	}
+	if v, err := vm.Eval(`(+ 1 2)`); err != nil || v.Value != int64(3) {
+		t.Errorf("got %v, %v, want 3", v, err)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := newTestVM(t).EvalContext(cancelled, `(+ 1 2)`)
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrSignalRecieved) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = newTestVM(t).EvalContext(ctx, `(try (loop true 1) (catch e 0))`)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrDeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = newTestVM(t).LoadReaderContext(ctx, strings.NewReader("(var n 0)\n(loop true (set n (+ n 1)))"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if err := newTestVM(t).LoadReaderContext(context.Background(), strings.NewReader("(+ 1 2)")); err != nil {
+		t.Errorf("unexpected error : %v", err)
+	}
+
+	// a blocking function returns early through the context of the evaluation
+	vm := newTestVM(t)
+	vm.Funcs["wait"] = func(vm *VM, a ...Variable) Variable {
+		select {
+		case <-vm.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+		return ligoNil
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = vm.EvalContext(ctx, `(wait) (+ 1 2)`)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the blocking function returned after %v", elapsed)
+	}
+
+	// the VM is usable with another context
+	if v, err := vm.EvalContext(context.Background(), `(+ 1 2)`); err != nil || v.Value != int64(3) {
+		t.Errorf("got %v, %v, want 3", v, err)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget Budget
+		code   string
+		// err is a part of the error expected
+		err string
+	}{
+		{"steps", Budget{Steps: 100}, `(loop true 1)`, "more than 100 steps"},
+		{"calls", Budget{Calls: 10}, `(fn f |x| x) (loop true (f 1))`, "more than 10 calls"},
+		{"not caught by try", Budget{Steps: 100}, `(try (loop true 1) (catch e 0))`, "steps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := newTestVM(t)
+			vm.SetBudget(tt.budget)
+			_, err := vm.Eval(tt.code)
+			if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected ErrBudgetExceeded with %q, got %v", tt.err, err)
+			}
+			// the next evaluation gets a budget of it's own
+			if _, err := vm.Eval(`(+ 1 2)`); err != nil {
+				t.Fatalf("unexpected error after exceeding the budget : %v", err)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	vm := newTestVM(t)
+	// the list and the two literals are the steps, + is the call
+	if _, err := vm.Eval(`(+ 1 2)`); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := vm.LastUsage(), (Usage{Steps: 3, Calls: 1}); got != want {
+		t.Fatalf("LastUsage : got %v, want %v", got, want)
+	}
+	if _, err := vm.Eval(`(fn f |x| x) (f 1) (f 2)`); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := vm.LastUsage(), (Usage{Steps: 7, Calls: 2}); got != want {
+		t.Fatalf("LastUsage : got %v, want %v", got, want)
+	}
+	_, usage, err := vm.EvalUsage(context.Background(), `(+ 1 2)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Usage{Steps: 3, Calls: 1}); usage != want {
+		t.Fatalf("EvalUsage : got %v, want %v", usage, want)
+	}
+	if got, want := vm.TotalUsage(), (Usage{Steps: 13, Calls: 4}); got != want {
+		t.Fatalf("TotalUsage : got %v, want %v", got, want)
+	}
+}
+
+// fill is the code pushing 100 items to an array one by one
+const fill = `(var xs []) (var i 0) (loop (< i 100) (progn (set xs (push xs i)) (set i (+ i 1))))`
+
+func TestMemoryStats(t *testing.T) {
+	vm := newTestVM(t)
+	if _, err := vm.Eval(fill); err != nil {
+		t.Fatal(err)
+	}
+	// only the growth of the array is counted for each push
+	if got, want := vm.Stats().Last.Bytes, int64(100*variableSize); got != want {
+		t.Fatalf("got %d bytes, want %d", got, want)
+	}
+	if _, err := vm.Eval(`[1 2 3]`); err != nil {
+		t.Fatal(err)
+	}
+	st := vm.Stats()
+	if st.Last.Bytes != 3*variableSize || st.Total.Bytes != 103*variableSize {
+		t.Fatalf("got %d bytes for the last and %d for all the evaluations", st.Last.Bytes, st.Total.Bytes)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	vm := newTestVM(t)
+	vm.SetMemoryLimit(1000)
+	v, err := vm.Eval(`(try (progn ` + fill + `) (catch "memory" e e:Kind))`)
+	if err != nil || v.Value != MemoryExceptionKind {
+		t.Fatalf("expected the memory exception to be caught, got %v, %v", v, err)
+	}
+	vm = newTestVM(t)
+	vm.SetMemoryLimit(1000)
+	_, err = vm.Eval(fill)
+	var e *Exception
+	if !errors.As(err, &e) || e.Kind != MemoryExceptionKind {
+		t.Fatalf("expected a memory exception, got %v", err)
+	}
+}
+
+func TestSpawnBudget(t *testing.T) {
+	vm := newTestVM(t)
+	vm.SetBudget(Budget{Steps: 50})
+	_, err := vm.Eval(`(fn spin |n| (join (spawn-vm (if (< n 100) (spin (+ n 1)) n)))) (spin 0)`)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if steps := vm.LastUsage().Steps; steps < 50 {
+		t.Fatalf("the steps of the actors are not counted, got %d", steps)
+	}
+}
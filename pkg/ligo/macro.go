@@ -0,0 +1,308 @@
+package ligo
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Macro is a function defined with defmacro. It is called with the argument
+// nodes as data (not evaluated) and the code it returns is evaluated in place
+// of the macro call.
+type Macro struct {
+	fn Defined
+}
+
+// DataOf function returns the passed node as data, so that code can be handled
+// like any other value. A symbol becomes a TypeSymbol value, a list becomes a
+// TypeList of the data of it's nodes and an array becomes a TypeArray of the data
+// of it's nodes. A literal is it's value, and a closure is kept as a TypeExp.
+func DataOf(n Node) Variable {
+	switch n := n.(type) {
+	case *Symbol:
+		return Variable{Type: TypeSymbol, Value: n.Name}
+	case *Literal:
+		return n.Value
+	case *List:
+		return Variable{Type: TypeList, Value: dataOfNodes(n.Nodes)}
+	case *Array:
+		return Variable{Type: TypeArray, Value: dataOfNodes(n.Nodes)}
+	}
+	return Variable{Type: TypeExp, Value: n}
+}
+
+// dataOfNodes function returns the data of each of the passed nodes
+func dataOfNodes(nodes []Node) []Variable {
+	vars := make([]Variable, 0, len(nodes))
+	for _, n := range nodes {
+		vars = append(vars, DataOf(n))
+	}
+	return vars
+}
+
+// NodeOf function returns the code represented by the passed data. It is the
+// reverse of DataOf. Any other value becomes a literal of itself. The nodes
+// created are given the passed position.
+func NodeOf(v Variable, pos Pos) (Node, error) {
+	switch v.Type {
+	case TypeSymbol:
+		return &Symbol{Name: v.Value.(string), pos: pos}, nil
+	case TypeList, TypeArray:
+		nodes, err := nodesOf(v.Value.([]Variable), pos)
+		if err != nil {
+			return nil, err
+		}
+		if v.Type == TypeList {
+			return &List{Nodes: nodes, pos: pos}, nil
+		}
+		return &Array{Nodes: nodes, pos: pos}, nil
+	case TypeExp:
+		n, ok := v.Value.(Node)
+		if !ok {
+			return nil, Error("expected an expression, got " + v.GetTypeString())
+		}
+		return n, nil
+	}
+	return &Literal{Value: v, raw: literalString(v), pos: pos}, nil
+}
+
+// nodesOf function returns the code represented by each of the passed values
+func nodesOf(vars []Variable, pos Pos) ([]Node, error) {
+	nodes := make([]Node, 0, len(vars))
+	for _, val := range vars {
+		n, err := NodeOf(val, pos)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// literalString function returns the source form of a value used as a literal
+func literalString(v Variable) string {
+	switch val := v.Value.(type) {
+	case string:
+		return strconv.Quote(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		str := strconv.FormatFloat(val, 'f', -1, 64)
+		if !rFloat.MatchString(str) {
+			str += ".0"
+		}
+		return str
+	case bool:
+		return strconv.FormatBool(val)
+	case nil:
+		return "nil"
+	}
+	return "<" + v.GetTypeString() + ">"
+}
+
+// quoteEval method is used to run the quote construct. The argument is returned
+// as data without evaluating it.
+func (vm *VM) quoteEval(tkns []Node) (Variable, error) {
+	if len(tkns) != 2 {
+		return ligoNil, Error("quote : expected a single expression to quote")
+	}
+	return DataOf(tkns[1]), nil
+}
+
+// quasiquoteEval method is used to run the quasiquote construct. The argument is
+// returned as data like quote, except the parts marked with unquote (evaluated)
+// and unquote-splicing (evaluated and spliced into the enclosing list).
+func (vm *VM) quasiquoteEval(tkns []Node) (Variable, error) {
+	if len(tkns) != 2 {
+		return ligoNil, Error("quasiquote : expected a single expression to quote")
+	}
+	return vm.quasiquote(tkns[1], 0)
+}
+
+// quasiquote method is used to convert the node to data, evaluating the unquoted
+// parts. depth is the number of the enclosing quasiquotes inside the outermost one,
+// only the unquotes at depth 0 are evaluated.
+func (vm *VM) quasiquote(n Node, depth int) (Variable, error) {
+	var nodes []Node
+	switch n := n.(type) {
+	case *List:
+		nodes = n.Nodes
+	case *Array:
+		nodes = n.Nodes
+	case *Closure:
+		if depth == 0 {
+			return vm.quasiquoteClosure(n)
+		}
+		return DataOf(n), nil
+	default:
+		return DataOf(n), nil
+	}
+
+	if len(nodes) == 2 {
+		switch {
+		case isSymbol(nodes[0], "unquote") && depth == 0:
+			return vm.eval(nodes[1])
+		case isSymbol(nodes[0], "unquote-splicing") && depth == 0:
+			return ligoNil, Error("unquote-splicing : can be used only inside a list or an array")
+		case isSymbol(nodes[0], "unquote"), isSymbol(nodes[0], "unquote-splicing"):
+			depth--
+		case isSymbol(nodes[0], "quasiquote"):
+			depth++
+		}
+	}
+
+	vars := make([]Variable, 0, len(nodes))
+	for _, val := range nodes {
+		l, ok := val.(*List)
+		if ok && depth == 0 && len(l.Nodes) == 2 && isSymbol(l.Nodes[0], "unquote-splicing") {
+			v, err := vm.eval(l.Nodes[1])
+			if err != nil {
+				return ligoNil, err
+			}
+			if v.Type != TypeList && v.Type != TypeArray {
+				return ligoNil, Error("unquote-splicing : expected a list or an array, got " + v.GetTypeString())
+			}
+			vars = append(vars, v.Value.([]Variable)...)
+			continue
+		}
+		v, err := vm.quasiquote(val, depth)
+		if err != nil {
+			return ligoNil, err
+		}
+		vars = append(vars, v)
+	}
+	if _, ok := n.(*Array); ok {
+		return Variable{Type: TypeArray, Value: vars}, nil
+	}
+	return Variable{Type: TypeList, Value: vars}, nil
+}
+
+// quasiquoteClosure method is used to fill the unquoted parameters of a closure,
+// so that the parameter names of a function can be passed to a macro. ,name is
+// replaced by the symbol in the variable name and ,@names by the symbols in the
+// list or array in the variable names.
+func (vm *VM) quasiquoteClosure(c *Closure) (Variable, error) {
+	params := make([]string, 0, len(c.Params))
+	for _, param := range c.Params {
+		if !strings.HasPrefix(param, ",") {
+			params = append(params, param)
+			continue
+		}
+		name := strings.TrimPrefix(param, ",")
+		splice := strings.HasPrefix(name, "@")
+		v, err := vm.parseToSymbol(strings.TrimPrefix(name, "@"))
+		if err != nil {
+			return ligoNil, err
+		}
+		vars := []Variable{v}
+		if splice {
+			if v.Type != TypeList && v.Type != TypeArray {
+				return ligoNil, Error("unquote-splicing : expected a list or an array, got " + v.GetTypeString())
+			}
+			vars = v.Value.([]Variable)
+		}
+		for _, val := range vars {
+			if val.Type != TypeSymbol {
+				return ligoNil, Error("unquote : expected a symbol as a parameter name, got " + val.GetTypeString())
+			}
+			params = append(params, val.Value.(string))
+		}
+	}
+	return Variable{Type: TypeExp, Value: &Closure{Params: params, pos: c.pos}}, nil
+}
+
+// misplacedUnquote method is used to report an unquote used outside a quasiquote
+func (vm *VM) misplacedUnquote(tkns []Node) (Variable, error) {
+	return ligoNil, Error(tkns[0].String() + " : can be used only inside a quasiquote")
+}
+
+// defMacro method is used to define a macro. It takes the same form as fn.
+func (vm *VM) defMacro(tkns []Node) (Variable, error) {
+	if len(tkns) != 4 {
+		return ligoNil, Error("defmacro : expected (defmacro NAME |PARAMS| BODY)")
+	}
+	sym, ok := tkns[1].(*Symbol)
+	if !ok {
+		return ligoNil, Error("defmacro : invalid macro name " + tkns[1].String())
+	}
+	name := sym.Name
+	varNames, err := getVarsFromClosure(tkns[2])
+	if err != nil {
+		return ligoNil, Error("In the macro definition " + name + " : " + err.Error())
+	}
+	fn := Defined{name: name, scopevars: varNames, body: tkns[3], env: vm}
+	vm.bind(name, Variable{Type: TypeMacro, Value: Macro{fn: fn}})
+	return ligoNil, nil
+}
+
+// expansion is the code a macro call expanded to. It is cached in the node of
+// the call, so that the macro is run only once for it. It is used as long as the
+// call refers to the same macro (defined by the same defmacro in the same scope).
+type expansion struct {
+	body Node
+	env  *sync.RWMutex
+	code Node
+}
+
+// expandCall method is used to expand the macro call n, reusing the expansion
+// cached in the node if there is one.
+func (vm *VM) expandCall(n *List, name string, m Macro) (Node, error) {
+	if e, ok := n.expansion.Load().(*expansion); ok && e.body == m.fn.body && e.env == m.fn.env.mu {
+		return e.code, nil
+	}
+	code, err := vm.expandMacro(name, n.Nodes[0].Pos(), m, n.Nodes[1:])
+	if err != nil {
+		return nil, err
+	}
+	n.expansion.Store(&expansion{body: m.fn.body, env: m.fn.env.mu, code: code})
+	return code, nil
+}
+
+// expandMacro method is used to call the macro with the passed argument nodes
+// as data, and return the code it returns. The code is given the position of
+// the macro call.
+func (vm *VM) expandMacro(name string, pos Pos, m Macro, args []Node) (Node, error) {
+	v, err := vm.runDefinedFunction(m.fn, name, pos, dataOfNodes(args))
+	if err != nil {
+		return nil, err
+	}
+	v, err = vm.resume(v)
+	if err != nil {
+		return nil, err
+	}
+	return NodeOf(v, pos)
+}
+
+// macroExpand method is used to run the macroexpand construct. The argument is
+// evaluated to a list and, if it is a macro call, the code it expands to is
+// returned as data without evaluating it.
+func (vm *VM) macroExpand(tkns []Node) (Variable, error) {
+	if len(tkns) != 2 {
+		return ligoNil, Error("macroexpand : expected a single expression")
+	}
+	v, err := vm.eval(tkns[1])
+	if err != nil {
+		return ligoNil, err
+	}
+	if v.Type != TypeList {
+		return v, nil
+	}
+	code := v.Value.([]Variable)
+	if len(code) == 0 || code[0].Type != TypeSymbol {
+		return v, nil
+	}
+	name := code[0].Value.(string)
+	m, err := vm.parseToSymbol(name)
+	if err != nil || m.Type != TypeMacro {
+		return v, nil
+	}
+	args, err := nodesOf(code[1:], tkns[0].Pos())
+	if err != nil {
+		return ligoNil, err
+	}
+	n, err := vm.expandMacro(name, tkns[0].Pos(), m.Value.(Macro), args)
+	if err != nil {
+		return ligoNil, err
+	}
+	return DataOf(n), nil
+}
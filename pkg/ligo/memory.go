@@ -0,0 +1,135 @@
+package ligo
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// variableSize is the approximate size of a Variable in bytes
+// (the type and the interface holding the value)
+const variableSize = 24
+
+// Stats is a snapshot of the statistics of a VM
+type Stats struct {
+	// Last is the work done by the last evaluation finished in the VM
+	Last Usage
+	// Total is the work done by all the evaluations finished in the VM
+	Total Usage
+	// MemoryLimit is the limit on the bytes allocated by an evaluation (0 for no limit)
+	MemoryLimit int64
+	// Vars, Funcs and Namespaces are the number of definitions in the scope of the VM
+	Vars       int
+	Funcs      int
+	Namespaces int
+	// HeapAlloc is the bytes of the go heap in use by the whole process
+	HeapAlloc uint64
+}
+
+// Stats method returns the statistics of the VM
+func (vm *VM) Stats() Stats {
+	mem := &runtime.MemStats{}
+	runtime.ReadMemStats(mem)
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+	vm.pc.Lock()
+	defer vm.pc.Unlock()
+	return Stats{
+		Last:        vm.pc.last,
+		Total:       vm.pc.total,
+		MemoryLimit: vm.pc.memoryLimit,
+		Vars:        len(vm.Vars),
+		Funcs:       len(vm.Funcs) + len(vm.LFuncs),
+		Namespaces:  len(vm.namespaces),
+		HeapAlloc:   mem.HeapAlloc,
+	}
+}
+
+// SetMemoryLimit method is used to set the limit on the approximate bytes of the
+// values (strings, arrays, maps and structs) allocated by each evaluation of the VM.
+// The bytes allocated are added up over the evaluation, the values no longer in use
+// are not taken off, so it is not a limit on the memory in use at a time.
+// Going over the limit throws an exception of the kind "memory", which can be caught.
+// The limit is checked after an in-built function returns, so the value it returned is
+// already allocated then. 0 means no limit. It is read by the evaluations without a lock, so it should be
+// set before the VM is shared by the goroutines.
+func (vm *VM) SetMemoryLimit(bytes int64) {
+	vm.pc.memoryLimit = bytes
+}
+
+// sizeOf function returns the approximate bytes allocated for the passed value.
+// Only the value itself is counted, the values it contains are counted when they
+// are created.
+func sizeOf(v Variable) int64 {
+	switch val := v.Value.(type) {
+	case string:
+		return int64(len(val))
+	case []Variable:
+		return int64(len(val)) * variableSize
+	case Map:
+		return int64(len(val)) * 2 * variableSize
+	case map[string]Variable:
+		return fieldsSize(val)
+	case *Record:
+		return fieldsSize(val.Fields)
+	}
+	return 0
+}
+
+// fieldsSize function returns the approximate bytes of the struct (or record) fields
+func fieldsSize(fields map[string]Variable) int64 {
+	fieldsLock.RLock()
+	defer fieldsLock.RUnlock()
+	size := int64(0)
+	for key := range fields {
+		size += int64(len(key)) + variableSize
+	}
+	return size
+}
+
+// argSizes function returns the sizes of the arguments of an in-built function.
+// They are measured before the call, as the function can grow them in place.
+func argSizes(args []Variable) []int64 {
+	sizes := make([]int64, len(args))
+	for i, arg := range args {
+		sizes[i] = sizeOf(arg)
+	}
+	return sizes
+}
+
+// allocResult method is used to count the value returned by an in-built function.
+// The value is often built from one of the arguments (like a map with a new key or
+// an array with a new item), so only it's growth over the largest argument of the
+// same type is counted. Otherwise building a container item by item would be
+// counted quadratically.
+func (vm *VM) allocResult(v Variable, args []Variable, sizes []int64) error {
+	size := sizeOf(v)
+	largest := int64(0)
+	for i, arg := range args {
+		if arg.Type == v.Type && sizes[i] > largest {
+			largest = sizes[i]
+		}
+	}
+	return vm.allocBytes(size - largest)
+}
+
+// alloc method is used to count the allocation of the passed value against the
+// memory limit. An exception is returned if it exceeds the limit, and the value
+// is not counted then.
+func (vm *VM) alloc(v Variable) error {
+	return vm.allocBytes(sizeOf(v))
+}
+
+// allocBytes method is used to count the passed bytes against the memory limit
+func (vm *VM) allocBytes(size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	n := atomic.AddInt64(&vm.state.meter.bytes, size)
+	if limit := vm.pc.memoryLimit; limit > 0 && n > limit {
+		atomic.AddInt64(&vm.state.meter.bytes, -size)
+		msg := fmt.Sprintf("memory limit of %d bytes exceeded allocating %d bytes", limit, size)
+		return vm.newException(MemoryExceptionKind, msg, Variable{Type: TypeInt, Value: size})
+	}
+	return nil
+}
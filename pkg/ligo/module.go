@@ -0,0 +1,436 @@
+package ligo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PackageLoader is the function used by the import construct to load the package
+// of the passed name (like "string") into the scope of the module, which is
+// passed as the VM. It is set by the host of the VM with SetPackageLoader.
+type PackageLoader func(vm *VM, name string) error
+
+// module is a package or a file loaded by the import construct. It is loaded
+// once for a VM, in it's own scope, and shared by all the imports of it.
+type module struct {
+	// name is the package name or the path of the file
+	name string
+	key  string
+	// file is true if the module is a file, not a package
+	file  bool
+	scope *VM
+	// exports holds the names passed to the export construct. If export is
+	// not used, everything defined in the module is exported.
+	exports  []string
+	exported bool
+	// imported holds the names bound in the module by it's own imports, which
+	// are not exported along with the rest
+	imported map[string]bool
+	// names holds the names exported, once the module is loaded
+	names map[string]bool
+	err   error
+	done  chan struct{}
+	// owner is the evaluation loading the module, nil once it is loaded
+	owner *evalState
+	mu    sync.Mutex
+}
+
+// moduleView is the module seen through the namespace of an import. The names
+// exported by it are looked up in the scope of the module when they are used,
+// so that the namespace always has their current values and nothing else of the
+// module is visible through it.
+type moduleView struct {
+	scope *VM
+	names map[string]bool
+}
+
+// binding method returns the value of the passed name in the module, if it is
+// visible through the view
+func (mv *moduleView) binding(name string) (Variable, bool) {
+	if !mv.names[name] {
+		return ligoNil, false
+	}
+	return mv.scope.getLocal(name)
+}
+
+// importFrame is a module being loaded by an evaluation, used to find the
+// import cycles
+type importFrame struct {
+	key  string
+	name string
+}
+
+// importSpec is the parsed form of an import construct
+type importSpec struct {
+	path  string
+	alias string
+	only  []string
+}
+
+// SetPackageLoader method is used to set the function loading the packages
+// imported by name. Only the files (paths like "./util.lg") can be imported if
+// it is not set.
+func (vm *VM) SetPackageLoader(loader PackageLoader) {
+	vm.pc.Lock()
+	defer vm.pc.Unlock()
+	vm.pc.loader = loader
+}
+
+// global method returns the outermost scope of the VM
+func (vm *VM) global() *VM {
+	for vm.parent != nil {
+		vm = vm.parent
+	}
+	return vm
+}
+
+// builtinScope method returns the parent of the scope a module is loaded in. It has
+// a copy of the in-built functions of the global scope (the keywords are found
+// anyway), so that a module can't see or set the variables and functions of the
+// scripts importing it.
+func (vm *VM) builtinScope() *VM {
+	g := vm.global()
+	funcs := make(map[string]InBuilt)
+	g.mu.RLock()
+	for name, fnc := range g.Funcs {
+		funcs[name] = fnc
+	}
+	g.mu.RUnlock()
+	return &VM{
+		Vars:       make(map[string]Variable),
+		Funcs:      funcs,
+		LFuncs:     make(map[string]Defined),
+		namespaces: make(map[string]*VM),
+		mu:         &sync.RWMutex{},
+		pc:         g.pc,
+		state:      vm.state,
+	}
+}
+
+// isFileImport function reports whether the imported path is a file rather than
+// the name of a package. A file is imported with a path relative to the importing
+// file (like "./util.lg" or "lib/util.lg") or an absolute path.
+func isFileImport(path string) bool {
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") ||
+		filepath.IsAbs(path) || strings.HasSuffix(path, ".lg")
+}
+
+// parseImport method is used to parse the import construct
+// (import PATH [:as ALIAS] [:only [NAME...]]), the path is evaluated.
+func (vm *VM) parseImport(tkns []Node) (importSpec, error) {
+	var spec importSpec
+	if len(tkns) < 2 {
+		return spec, Error("import : expected (import PATH [:as ALIAS] [:only [NAME...]])")
+	}
+	path, err := vm.eval(tkns[1])
+	if err != nil {
+		return spec, err
+	}
+	if path.Type != TypeString {
+		return spec, Error("import : expected the path to be a string, got " + path.GetTypeString())
+	}
+	spec.path = path.Value.(string)
+	seen := map[string]bool{}
+	for i := 2; i < len(tkns); i += 2 {
+		option := tkns[i].String()
+		if seen[option] {
+			return spec, Error("import : option " + option + " passed more than once")
+		}
+		seen[option] = true
+		if i+1 >= len(tkns) {
+			return spec, Error("import : expected a value for the option " + option)
+		}
+		switch option {
+		case ":as":
+			alias, ok := tkns[i+1].(*Symbol)
+			if !ok || !rVariable.MatchString(alias.Name) {
+				return spec, Error("import : invalid alias " + tkns[i+1].String())
+			}
+			spec.alias = alias.Name
+		case ":only":
+			names, ok := tkns[i+1].(*Array)
+			if !ok {
+				return spec, Error("import : expected an array of names for :only, got " + tkns[i+1].String())
+			}
+			spec.only = make([]string, 0, len(names.Nodes))
+			for _, n := range names.Nodes {
+				name, ok := n.(*Symbol)
+				if !ok {
+					return spec, Error("import : invalid name " + n.String() + " in :only")
+				}
+				spec.only = append(spec.only, name.Name)
+			}
+		default:
+			return spec, Error("import : unknown option " + option + ", expected :as or :only")
+		}
+	}
+	if spec.alias == "" && spec.only == nil {
+		name := strings.TrimSuffix(filepath.Base(spec.path), ".lg")
+		if !rVariable.MatchString(name) {
+			return spec, Error("import : can't name the module " + spec.path + ", pass a name with :as")
+		}
+		spec.alias = name
+	}
+	return spec, nil
+}
+
+// importEval method is used to run the import construct. The module is loaded
+// (if not loaded already) and the names exported by it are made visible in the
+// current scope. They are seen through a namespace named by the alias (by default
+// the name of the package or file), or bound directly if :only is passed without
+// an alias. Importing a module while it is being loaded is an import cycle.
+func (vm *VM) importEval(tkns []Node) (Variable, error) {
+	spec, err := vm.parseImport(tkns)
+	if err != nil {
+		return ligoNil, err
+	}
+	key, path, file := spec.path, spec.path, isFileImport(spec.path)
+	if file {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(tkns[0].Pos().File), spec.path)
+		}
+		if key, err = filepath.Abs(path); err != nil {
+			return ligoNil, Error("import : " + err.Error())
+		}
+	}
+	m, err := vm.loadModule(key, path, file)
+	if err != nil {
+		return ligoNil, err
+	}
+
+	names := m.names
+	if spec.only != nil {
+		names = make(map[string]bool, len(spec.only))
+		for _, name := range spec.only {
+			if !m.names[name] {
+				return ligoNil, Error("import : '" + name + "' is not exported by " + spec.path)
+			}
+			names[name] = true
+		}
+	}
+	if spec.alias == "" {
+		for name := range names {
+			v, _ := m.scope.getLocal(name)
+			vm.bind(name, v)
+		}
+		if vm.module != nil {
+			vm.module.mu.Lock()
+			if vm.module.imported == nil {
+				vm.module.imported = make(map[string]bool)
+			}
+			for name := range names {
+				vm.module.imported[name] = true
+			}
+			vm.module.mu.Unlock()
+		}
+		return ligoNil, nil
+	}
+	// the namespace is not a scope of the module, so that the names it doesn't
+	// export can't be reached through it
+	namespace := vm.global().NewScope()
+	namespace.view = &moduleView{scope: m.scope, names: names}
+	vm.mu.Lock()
+	vm.namespaces[spec.alias] = namespace
+	vm.mu.Unlock()
+	return ligoNil, nil
+}
+
+// loadModule method returns the module of the passed key, loading it from the
+// path if it is not loaded yet. If the module is being loaded by another
+// evaluation, it waits for it (unless that is an import cycle).
+func (vm *VM) loadModule(key, path string, file bool) (*module, error) {
+	for _, loading := range vm.state.imports {
+		if loading.key == key {
+			return nil, vm.importCycle(key, path)
+		}
+	}
+	vm.pc.Lock()
+	if vm.pc.modules == nil {
+		vm.pc.modules = make(map[string]*module)
+	}
+	if m, ok := vm.pc.modules[key]; ok {
+		err := vm.waitCycle(m)
+		if err == nil {
+			vm.state.waiting = m
+		}
+		vm.pc.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return vm.waitModule(m)
+	}
+	m := &module{name: path, key: key, file: file, done: make(chan struct{}), owner: vm.state}
+	vm.pc.modules[key] = m
+	vm.state.imports = append(vm.state.imports, importFrame{key: key, name: path})
+	loader := vm.pc.loader
+	vm.pc.Unlock()
+
+	scope := vm.builtinScope().NewScope()
+	scope.module = m
+	m.scope = scope.withState(vm.state)
+	m.err = m.load(loader)
+	vm.pc.Lock()
+	vm.state.imports = vm.state.imports[:len(vm.state.imports)-1]
+	m.owner = nil
+	if m.err != nil {
+		delete(vm.pc.modules, key)
+	}
+	vm.pc.Unlock()
+	close(m.done)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m, nil
+}
+
+// waitModule method is used to wait for the module being loaded by another
+// evaluation. The waiting of the evaluation should be set to the module.
+func (vm *VM) waitModule(m *module) (*module, error) {
+	defer func() {
+		vm.pc.Lock()
+		vm.state.waiting = nil
+		vm.pc.Unlock()
+	}()
+	select {
+	case <-m.done:
+	case <-vm.state.done:
+		return nil, vm.state.interrupted()
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m, nil
+}
+
+// waitCycle method returns the import cycle error if the module is being loaded
+// by another evaluation which is waiting (maybe through other evaluations) for a
+// module loaded by the current one. Waiting for the module would never end then.
+// The process control should be locked by the caller.
+func (vm *VM) waitCycle(m *module) error {
+	var chain []string
+	seen := map[*evalState]bool{}
+	for m != nil && m.owner != nil && !seen[m.owner] {
+		st := m.owner
+		if st == vm.state {
+			for i, loading := range st.imports {
+				if loading.key == m.key {
+					chain = append(append(importNames(st.imports[i:]), chain...), m.name)
+					break
+				}
+			}
+			return Error("import : import cycle : " + strings.Join(chain, " -> "))
+		}
+		seen[st] = true
+		for i, loading := range st.imports {
+			if loading.key == m.key {
+				chain = append(chain, importNames(st.imports[i:])...)
+				break
+			}
+		}
+		m = st.waiting
+	}
+	return nil
+}
+
+// importNames function returns the names of the modules being loaded
+func importNames(frames []importFrame) []string {
+	names := make([]string, 0, len(frames))
+	for _, loading := range frames {
+		names = append(names, loading.name)
+	}
+	return names
+}
+
+// load method is used to load the module from the file or the package, and
+// collect the names exported by it. The values are looked up in the scope of the
+// module when they are used.
+func (m *module) load(loader PackageLoader) error {
+	if m.file {
+		file, err := os.Open(m.name)
+		if err != nil {
+			return Error("import : " + err.Error())
+		}
+		defer file.Close()
+		if err := m.scope.LoadReader(file); err != nil {
+			return err
+		}
+	} else {
+		if loader == nil {
+			return Error("import : can't import the package " + m.name + ", no package loader is set")
+		}
+		if err := loader(m.scope, m.name); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.names = make(map[string]bool)
+	if !m.exported {
+		m.scope.mu.RLock()
+		defer m.scope.mu.RUnlock()
+		for name := range m.scope.Vars {
+			m.names[name] = true
+		}
+		for name := range m.scope.Funcs {
+			m.names[name] = true
+		}
+		for name := range m.scope.LFuncs {
+			m.names[name] = true
+		}
+		for name := range m.imported {
+			delete(m.names, name)
+		}
+		return nil
+	}
+	for _, name := range m.exports {
+		if _, ok := m.scope.getLocal(name); !ok {
+			return Error("import : " + m.name + " exports '" + name + "', which is not defined in it")
+		}
+		m.names[name] = true
+	}
+	return nil
+}
+
+// importCycle method returns the error for importing the module of the passed
+// key, which is being loaded by the current evaluation
+func (vm *VM) importCycle(key, path string) error {
+	chain := make([]string, 0, len(vm.state.imports)+1)
+	found := false
+	for _, loading := range vm.state.imports {
+		found = found || loading.key == key
+		if found {
+			chain = append(chain, loading.name)
+		}
+	}
+	chain = append(chain, path)
+	return Error("import : import cycle : " + strings.Join(chain, " -> "))
+}
+
+// exportEval method is used to run the export construct, which sets the names
+// the module (being loaded) makes visible to the importers. The rest of the
+// definitions are private to the module.
+func (vm *VM) exportEval(tkns []Node) (Variable, error) {
+	var m *module
+	for scope := vm; scope != nil && m == nil; scope = scope.parent {
+		m = scope.module
+	}
+	if m == nil {
+		return ligoNil, Error("export : used outside of an imported module")
+	}
+	names := make([]string, 0, len(tkns)-1)
+	for _, n := range tkns[1:] {
+		name, ok := n.(*Symbol)
+		if !ok {
+			return ligoNil, Error("export : invalid name " + n.String())
+		}
+		names = append(names, name.Name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exports = append(m.exports, names...)
+	m.exported = true
+	return ligoNil, nil
+}
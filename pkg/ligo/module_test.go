@@ -0,0 +1,151 @@
+package ligo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeModules function writes the passed ligo files (by their names) to a
+// temporary directory and returns it
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFileImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.lg":       "(import \"lib/shapes.lg\" :as shapes)\n(var area (shapes.square 3))\n",
+		"lib/shapes.lg": "(import \"./util.lg\" :only [twice])\n(fn square |x| (twice x x))\n",
+		"lib/util.lg":   "(fn twice |a b| (+ a b))\n",
+	})
+	vm := newTestVM(t)
+	v, err := vm.Eval(`(import "` + filepath.Join(dir, "main.lg") + `" :only [area]) area`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Value.(int64) != 6 {
+		t.Fatalf("got %v, want 6", v)
+	}
+}
+
+func TestDefaultExports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"util.lg": "(fn twice |a b| (+ a b))\n",
+		"quad.lg": "(import \"./util.lg\" :only [twice])\n(fn quad |x| (twice (twice x x) (twice x x)))\n",
+	})
+	vm := newTestVM(t)
+	v, err := vm.Eval(`(import "` + filepath.Join(dir, "quad.lg") + `") (quad.quad 2)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Value.(int64) != 8 {
+		t.Fatalf("got %v, want 8", v)
+	}
+	if _, err := vm.Eval(`(quad.twice 1 2)`); err == nil {
+		t.Fatal("the name imported by the module is exported by it")
+	}
+}
+
+func TestImportCycleAcrossEvaluations(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.lg": "(pause)\n(import \"./b.lg\")\n",
+		"b.lg": "(pause)\n(import \"./a.lg\")\n",
+	})
+	vm := newTestVM(t)
+	vm.Funcs["pause"] = func(vm *VM, a ...Variable) Variable {
+		time.Sleep(50 * time.Millisecond)
+		return ligoNil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parallel(t, 2, func(g int) error {
+			name := filepath.Join(dir, string(rune('a'+g))+".lg")
+			_, err := vm.Eval(`(import "` + name + `")`)
+			if err == nil || !strings.Contains(err.Error(), "import cycle") {
+				return fmt.Errorf("importing %s : expected an import cycle, got %v", name, err)
+			}
+			return nil
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the imports are waiting for each other")
+	}
+}
+
+func TestAliasPrivateNames(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"m.lg": "(export visible)\n(var secret 42)\n(var visible 1)\n",
+	})
+	vm := newTestVM(t)
+	if _, err := vm.Eval(`(import "` + filepath.Join(dir, "m.lg") + `" :as m)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{`(namespace m secret)`, `m.secret`, `(namespace m (set secret 0))`} {
+		if v, err := vm.Eval(code); err == nil {
+			t.Errorf("%s : the private name is visible through the alias, got %v", code, v)
+		}
+	}
+	v, err := vm.Eval(`(namespace m visible)`)
+	if err != nil || v.Value.(int64) != 1 {
+		t.Fatalf("got %v, %v, want 1", v, err)
+	}
+}
+
+func TestLiveExports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"c.lg": "(export n inc)\n(var n 0)\n(fn inc || (set n (+ n 1)))\n",
+	})
+	vm := newTestVM(t)
+	v, err := vm.Eval(`(import "` + filepath.Join(dir, "c.lg") + `") (c.inc) (c.inc) c.n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Value.(int64) != 2 {
+		t.Fatalf("got %v, want 2", v)
+	}
+}
+
+func TestModuleIsolation(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"see.lg":  "(var seen secret)\n",
+		"set.lg":  "(set secret 0)\n",
+		"call.lg": "(var called (helper 1))\n",
+		"ok.lg":   "(var sum (+ 1 2))\n",
+	})
+	vm := newTestVM(t)
+	if _, err := vm.Eval(`(var secret 42) (fn helper |x| x)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"see.lg", "set.lg", "call.lg"} {
+		if _, err := vm.Eval(`(import "` + filepath.Join(dir, name) + `")`); err == nil {
+			t.Errorf("%s : the module reached a definition of the importer", name)
+		}
+	}
+	v, err := vm.Eval(`secret`)
+	if err != nil || v.Value.(int64) != 42 {
+		t.Fatalf("got %v, %v, want 42", v, err)
+	}
+
+	// the in-built functions of the VM are found by the modules
+	v, err = vm.Eval(`(import "` + filepath.Join(dir, "ok.lg") + `") ok.sum`)
+	if err != nil || v.Value.(int64) != 3 {
+		t.Fatalf("got %v, %v, want 3", v, err)
+	}
+}
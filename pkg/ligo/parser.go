@@ -1,181 +1,237 @@
 package ligo
 
 import (
-	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type parser struct {
-	strList  []string
-	inQuotes bool
-	inSBkts  bool
-	current  string
-	ltxt     string
-	i        int
+	file  string
+	ltxt  string
+	i     int
+	lines []int
 }
 
 // newParser method is used to create a new instance of parser and return it
-func newParser(ltxt string) *parser {
+func newParser(file, ltxt string) *parser {
 	p := &parser{}
-	p.strList = make([]string, 0)
+	p.file = file
 	p.ltxt = ltxt
+	p.lines = []int{0}
+	for i := 0; i < len(ltxt); i++ {
+		if ltxt[i] == '\n' {
+			p.lines = append(p.lines, i+1)
+		}
+	}
 	return p
 }
 
-// handleWhiteSpace method is used to update the parser state when a whitespace character is passed
-// " ","\n","\r","\t"
-func (p *parser) handleWhiteSpace(c string) error {
-	if !p.inQuotes && !p.inSBkts {
-		if p.current == "" {
-			return nil
+// Parse function is used to parse a ligo source into a list of top level nodes.
+func Parse(ltxt string) ([]Node, error) {
+	return ParseFile("", ltxt)
+}
+
+// ParseFile function is used to parse a ligo source read from the passed file.
+// The file name is recorded in the position of every node.
+func ParseFile(file, ltxt string) ([]Node, error) {
+	return newParser(file, ltxt).parseAll()
+}
+
+// pos method is used to get the line and column position of the passed offset
+func (p *parser) pos(off int) Pos {
+	line := sort.Search(len(p.lines), func(i int) bool { return p.lines[i] > off }) - 1
+	column := utf8.RuneCountInString(p.ltxt[p.lines[line]:off]) + 1
+	return Pos{File: p.file, Line: line + 1, Column: column}
+}
+
+// errorAt method is used to create a syntax error at the passed offset
+func (p *parser) errorAt(off int, msg string) error {
+	return &SourceError{Pos: p.pos(off), Err: ErrSyntaxError + Error(" : "+msg)}
+}
+
+// parseAll method is used to parse all the nodes till the end of the source
+func (p *parser) parseAll() ([]Node, error) {
+	nodes := make([]Node, 0)
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nodes, nil
 		}
-		p.strList = append(p.strList, p.current)
-		p.current = ""
-		return nil
+		n, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
 	}
-	p.current += c
-	return nil
 }
 
-// handleClosure method is used to update the parser state when a bar is passed (|)
-func (p *parser) handleClosure(c string) error {
-	if p.inSBkts {
-		p.current += c
-		p.inSBkts = false
-		return nil
-	}
-	if !p.inQuotes {
-		if p.current != "" {
-			return Error("Closure not separated by a space")
+// eof method is used to check whether the parser reached the end of the source
+func (p *parser) eof() bool {
+	return p.i >= len(p.ltxt)
+}
+
+// skipSpace method is used to skip the whitespace characters and comments
+// (" ","\n","\r","\t" and anything from ';' till the end of line)
+func (p *parser) skipSpace() {
+	for !p.eof() {
+		switch p.ltxt[p.i] {
+		case ' ', '\n', '\r', '\t':
+			p.i++
+		case ';':
+			for !p.eof() && p.ltxt[p.i] != '\n' {
+				p.i++
+			}
+		default:
+			return
 		}
-		p.current += c
-		p.inSBkts = true
-		return nil
 	}
-	p.current += c
-	return nil
 }
 
-// handleQuote method is used to update the parser state when a double-quote is passed
-func (p *parser) handleQuote(c string) error {
-	if !p.inQuotes {
-		p.inQuotes = true
-		if p.current != "" {
-			return Error("Not separated by a space")
+// parseNode method is used to parse a single node starting at the current position
+func (p *parser) parseNode() (Node, error) {
+	start := p.i
+	switch p.ltxt[p.i] {
+	case '(':
+		nodes, err := p.parseSeq('(', ')')
+		if err != nil {
+			return nil, err
+		}
+		return &List{Nodes: nodes, pos: p.pos(start)}, nil
+	case '[':
+		nodes, err := p.parseSeq('[', ']')
+		if err != nil {
+			return nil, err
 		}
-		p.current += "\""
-		return nil
+		return &Array{Nodes: nodes, pos: p.pos(start)}, nil
+	case ')', ']':
+		return nil, p.errorAt(start, "Unexpected '"+string(p.ltxt[p.i])+"' found")
+	case '|':
+		return p.parseClosure()
+	case '"':
+		return p.parseString()
+	case '\'':
+		return p.parsePrefixed("quote", 1)
+	case '`':
+		return p.parsePrefixed("quasiquote", 1)
+	case ',':
+		if strings.HasPrefix(p.ltxt[p.i:], ",@") {
+			return p.parsePrefixed("unquote-splicing", 2)
+		}
+		return p.parsePrefixed("unquote", 1)
 	}
-	p.current += "\""
-	p.strList = append(p.strList, p.current)
-	p.inQuotes = false
-	p.current = ""
-	return nil
+	return p.parseAtom()
 }
 
-// handleOpenSquareBracket method is used to update the parser state when a open square bracket is passed
-func (p *parser) handleOpenSquareBracket(c string) error {
-	if p.inSBkts {
-		return Error("'[' not expected inside a closure")
-	}
-	if p.inQuotes {
-		p.current += c
-		return nil
-	}
-	if p.current != "" {
-		return Error("Array not separated by a space")
-	}
-	off := MatchChars(p.ltxt, int64(p.i), '[', ']') + 1
-	p.current = p.ltxt[p.i:off]
-	p.strList = append(p.strList, p.current)
-	p.i = int(off)
-	if strings.TrimSpace(string(p.ltxt[p.i])) != "" && strings.TrimSpace(string(p.ltxt[p.i])) != ")" {
-		return Error("Unexpected character found at array end : " + string(p.ltxt[p.i]))
-	}
-	p.current = ""
-	return nil
+// parsePrefixed method is used to parse the short forms of quote ('x), quasiquote (`x),
+// unquote (,x) and unquote-splicing (,@x) into a list like (quote x). width is the
+// length of the prefix.
+func (p *parser) parsePrefixed(name string, width int) (Node, error) {
+	start := p.i
+	p.i += width
+	if p.eof() || strings.ContainsRune(" \n\r\t;)]", rune(p.ltxt[p.i])) {
+		return nil, p.errorAt(start, "Expected an expression after "+p.ltxt[start:start+width])
+	}
+	n, err := p.parseNode()
+	if err != nil {
+		return nil, err
+	}
+	pos := p.pos(start)
+	return &List{Nodes: []Node{&Symbol{Name: name, pos: pos}, n}, pos: pos}, nil
 }
 
-// handleOpenParen method is used to update the parser state when a open parenthesis ("(") is passed
-func (p *parser) handleOpenParen(c string) error {
-	if p.inSBkts {
-		return Error("'(' not expected inside a closure")
-	}
-	if p.inQuotes {
-		p.current += c
-		return nil
-	}
-	if p.current != "" {
-		return Error("Expression not separated by a space")
-	}
-	off := MatchChars(p.ltxt, int64(p.i), '(', ')') + 1
-	p.current = p.ltxt[p.i:off]
-	p.strList = append(p.strList, p.current)
-	p.i = int(off)
-	if strings.TrimSpace(string(p.ltxt[p.i])) != "" && strings.TrimSpace(string(p.ltxt[p.i])) != ")" {
-		return Error("Unexpected character found at expression end : " + string(p.ltxt[p.i]))
+// parseSeq method is used to parse the nodes enclosed by the passed open and close characters
+func (p *parser) parseSeq(open, close byte) ([]Node, error) {
+	start := p.i
+	p.i++
+	nodes := make([]Node, 0)
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorAt(start, "'"+string(open)+"' not closed correctly")
+		}
+		if p.ltxt[p.i] == close {
+			p.i++
+			return nodes, nil
+		}
+		n, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		if !p.eof() && !strings.ContainsRune(" \n\r\t;)]", rune(p.ltxt[p.i])) {
+			return nil, p.errorAt(p.i, "Unexpected character found after "+n.String()+" : "+string(p.ltxt[p.i]))
+		}
+		nodes = append(nodes, n)
 	}
-	p.current = ""
-	return nil
 }
 
-// handleCloseParen method is used to update the parser state when a close parenthesis (")") is passed
-func (p *parser) handleCloseParen(c string) error {
-	if p.inSBkts {
-		return Error("')' not expected inside a closure")
-	}
-	if p.inQuotes {
-		p.current += c
-		return nil
-	}
-	if len(p.ltxt)-1 != p.i {
-		return Error("Expected EOL, got " + string(p.ltxt[p.i]) + " at " + fmt.Sprint(p.i))
-	}
-	if p.current != "" {
-		p.strList = append(p.strList, p.current)
-		p.current = ""
-	}
-	return nil
+// parseClosure method is used to parse the parameter list of a function construct
+func (p *parser) parseClosure() (Node, error) {
+	start := p.i
+	end := strings.IndexByte(p.ltxt[p.i+1:], '|')
+	if end < 0 {
+		return nil, p.errorAt(start, "Closure not closed correctly")
+	}
+	cl := p.ltxt[p.i+1 : p.i+1+end]
+	if strings.ContainsAny(cl, "()[]\";") {
+		return nil, p.errorAt(start, "Unexpected character inside a closure : |"+cl+"|")
+	}
+	p.i += end + 2
+	return &Closure{Params: strings.Fields(cl), pos: p.pos(start)}, nil
 }
 
-// handleCloseSquareBracket method is used to update the parser state when a close square bracket ("]") is passed
-func (p *parser) handleCloseSquareBracket(c string) error {
-	if p.inSBkts {
-		return Error("']' not expected inside a closure")
+// parseString method is used to parse a double-quoted string literal
+func (p *parser) parseString() (Node, error) {
+	start := p.i
+	end := -1
+	for i := p.i + 1; i < len(p.ltxt); i++ {
+		if p.ltxt[i] == '\\' {
+			i++
+			continue
+		}
+		if p.ltxt[i] == '"' {
+			end = i
+			break
+		}
 	}
-	if p.inQuotes {
-		p.current += c
-		return nil
+	if end < 0 {
+		return nil, p.errorAt(start, "Quote not closed correctly")
 	}
-	p.strList = append(p.strList, p.current)
-	p.current = ""
-	return nil
-}
-
-// handleDefault method is used to update the parser state when a normal character (which is not a symbol) is passed
-func (p *parser) handleDefault(c string) error {
-	p.current += c
-	return nil
+	raw := p.ltxt[start : end+1]
+	p.i = end + 1
+	str, off, err := reformEscapes(raw[1 : len(raw)-1])
+	if err != nil {
+		return nil, p.errorAt(start+1+off, err.Error())
+	}
+	return &Literal{Value: Variable{Type: TypeString, Value: str}, raw: raw, pos: p.pos(start)}, nil
 }
 
-// runHandler method runs a handler function corresponding to the string passed and returns the error
-// result from the handler
-func (p *parser) runHandler(c string) error {
-	switch c {
-	case " ", "\n", "\r", "\t":
-		return p.handleWhiteSpace(c)
-	case "|":
-		return p.handleClosure(c)
-	case "\"":
-		return p.handleQuote(c)
-	case "[":
-		return p.handleOpenSquareBracket(c)
-	case "(":
-		return p.handleOpenParen(c)
-	case ")":
-		return p.handleCloseParen(c)
-	case "]":
-		return p.handleCloseSquareBracket(c)
+// parseAtom method is used to parse a number, a boolean or a symbol
+func (p *parser) parseAtom() (Node, error) {
+	start := p.i
+	for !p.eof() && !strings.ContainsRune(" \n\r\t;()[]\"|", rune(p.ltxt[p.i])) {
+		p.i++
+	}
+	token := p.ltxt[start:p.i]
+	pos := p.pos(start)
+	switch true {
+	case rInteger.MatchString(token):
+		num, err := strconv.ParseInt(token, 10, 64)
+		if err != nil {
+			return nil, p.errorAt(start, err.Error())
+		}
+		return &Literal{Value: Variable{Type: TypeInt, Value: num}, raw: token, pos: pos}, nil
+	case rFloat.MatchString(token):
+		num, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, p.errorAt(start, err.Error())
+		}
+		return &Literal{Value: Variable{Type: TypeFloat, Value: num}, raw: token, pos: pos}, nil
+	case token == "true":
+		return &Literal{Value: Variable{Type: TypeBool, Value: true}, raw: token, pos: pos}, nil
+	case token == "false":
+		return &Literal{Value: Variable{Type: TypeBool, Value: false}, raw: token, pos: pos}, nil
 	}
-	return p.handleDefault(c)
+	return &Symbol{Name: token, pos: pos}, nil
 }
@@ -0,0 +1,158 @@
+package ligo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		// want is the source form of the parsed nodes, joined by a newline
+		want string
+		// err is a part of the error expected, if any
+		err string
+	}{
+		{name: "list", code: `(+ 1 2)`, want: `(+ 1 2)`},
+		{name: "nested", code: `(fn add |a b| (+ a b))`, want: `(fn add |a b| (+ a b))`},
+		{name: "array", code: `[1 2.5 "a" true]`, want: `[1 2.5 "a" true]`},
+		{name: "top level nodes", code: "(var a 1)\n(var b 2)", want: "(var a 1)\n(var b 2)"},
+		{name: "whitespace", code: "(+\t1\r\n  2 )", want: `(+ 1 2)`},
+		{name: "comments", code: "; a comment\n(+ 1 2) ; another\n", want: `(+ 1 2)`},
+		{name: "quote", code: `'a`, want: `(quote a)`},
+		{name: "quasiquote", code: "`(a ,b ,@c)", want: `(quasiquote (a (unquote b) (unquote-splicing c)))`},
+		{name: "empty", code: "  ; nothing\n", want: ``},
+		{name: "unclosed list", code: `(+ 1 2`, err: "1:1: Syntax Error : '(' not closed correctly"},
+		{name: "unclosed array", code: `[1 2`, err: "'[' not closed correctly"},
+		{name: "unexpected close", code: `(+ 1 2))`, err: "1:8: Syntax Error : Unexpected ')' found"},
+		{name: "unclosed quote", code: `(println "a)`, err: "1:10: Syntax Error : Quote not closed correctly"},
+		{name: "unclosed closure", code: `(fn a |b (+ b 1))`, err: "Closure not closed correctly"},
+		{name: "bad closure", code: `(fn a |b (c)| b)`, err: "Unexpected character inside a closure"},
+		{name: "character after a node", code: `("a"b)`, err: `1:5: Syntax Error : Unexpected character found after "a" : b`},
+		{name: "nothing quoted", code: `(a ' b)`, err: "Expected an expression after '"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nodes, err := Parse(tc.code)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Parse(%q) error = %v, want %q", tc.code, err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) : %v", tc.code, err)
+			}
+			strs := make([]string, 0, len(nodes))
+			for _, n := range nodes {
+				strs = append(strs, n.String())
+			}
+			if got := strings.Join(strs, "\n"); got != tc.want {
+				t.Errorf("Parse(%q) = %q, want %q", tc.code, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseNodes(t *testing.T) {
+	nodes, err := Parse(`(fn f |a ...rest| [a 1 1.5 "s" false])`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, ok := nodes[0].(*List)
+	if !ok || len(list.Nodes) != 4 {
+		t.Fatalf("got %#v, want a list of 4 nodes", nodes[0])
+	}
+	if s, ok := list.Nodes[1].(*Symbol); !ok || s.Name != "f" {
+		t.Errorf("got %#v, want the symbol f", list.Nodes[1])
+	}
+	if c, ok := list.Nodes[2].(*Closure); !ok || strings.Join(c.Params, ",") != "a,...rest" {
+		t.Errorf("got %#v, want the closure |a ...rest|", list.Nodes[2])
+	}
+	arr, ok := list.Nodes[3].(*Array)
+	if !ok || len(arr.Nodes) != 5 {
+		t.Fatalf("got %#v, want an array of 5 nodes", list.Nodes[3])
+	}
+	if s, ok := arr.Nodes[0].(*Symbol); !ok || s.Name != "a" {
+		t.Errorf("got %#v, want the symbol a", arr.Nodes[0])
+	}
+	want := []Variable{
+		{Type: TypeInt, Value: int64(1)},
+		{Type: TypeFloat, Value: 1.5},
+		{Type: TypeString, Value: "s"},
+		{Type: TypeBool, Value: false},
+	}
+	for i, w := range want {
+		l, ok := arr.Nodes[i+1].(*Literal)
+		if !ok || !Equal(l.Value, w) {
+			t.Errorf("item %d = %#v, want %v", i+1, arr.Nodes[i+1], w)
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	nodes, err := ParseFile("main.lg", "(var a 1)\n\n  (println\n    \"é\" a)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := nodes[1].(*List)
+	tests := []struct {
+		node Node
+		want string
+	}{
+		{nodes[0], "main.lg:1:1"},
+		{nodes[0].(*List).Nodes[2], "main.lg:1:8"},
+		{second, "main.lg:3:3"},
+		{second.Nodes[0], "main.lg:3:4"},
+		{second.Nodes[1], "main.lg:4:5"},
+		// the column counts the characters, not the bytes
+		{second.Nodes[2], "main.lg:4:9"},
+	}
+	for _, tc := range tests {
+		if got := tc.node.Pos().String(); got != tc.want {
+			t.Errorf("position of %s = %s, want %s", tc.node, got, tc.want)
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+		// err is the error expected, if any
+		err string
+	}{
+		{name: "table", code: `"a\tb\n\\"`, want: "a\tb\n\\"},
+		{name: "quote", code: `"say \"hi\""`, want: `say "hi"`},
+		{name: "hex", code: `"\x41\x62"`, want: "Ab"},
+		{name: "octal", code: `"\101\60\0"`, want: "A0\x00"},
+		{name: "short unicode", code: `"caf\u00e9"`, want: "café"},
+		{name: "long unicode", code: `"\U0001F600!"`, want: "😀!"},
+		{name: "unknown", code: `(var a "ab\qc")`, err: `1:11: Syntax Error : Unknown Escape sequence : '\q'`},
+		{name: "unknown on a later line", code: "(var a 1)\n  \"é\\q\"", err: `2:5: Syntax Error : Unknown Escape sequence : '\q'`},
+		{name: "bad hex", code: `"\xZZ"`, err: `1:2: Syntax Error : Invalid hex digits in the escape sequence : '\xZZ'`},
+		{name: "incomplete unicode", code: `"ab\u12"`, err: `1:4: Syntax Error : Incomplete escape sequence : '\u12'`},
+		{name: "invalid code point", code: `"\UFFFFFFFF"`, err: "Invalid unicode code point"},
+		{name: "octal out of range", code: `"\777"`, err: "Octal escape sequence out of range"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nodes, err := Parse(tc.code)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Parse(%q) error = %v, want %q", tc.code, err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) : %v", tc.code, err)
+			}
+			l, ok := nodes[0].(*Literal)
+			if !ok || l.Value.Value != tc.want {
+				t.Errorf("Parse(%q) = %#v, want %q", tc.code, nodes[0], tc.want)
+			}
+		})
+	}
+}
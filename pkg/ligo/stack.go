@@ -0,0 +1,131 @@
+package ligo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Frame is an entry in the call stack of the VM denoting a function call
+type Frame struct {
+	Name string
+	Pos  Pos
+}
+
+// StackTrace is a list of call frames with the innermost call first
+type StackTrace []Frame
+
+// String method returns the stack trace with one frame on each line
+func (st StackTrace) String() string {
+	str := ""
+	for _, frame := range st {
+		str += "\tat " + frame.Name
+		if frame.Pos.Line != 0 {
+			str += " (" + frame.Pos.String() + ")"
+		}
+		str += "\n"
+	}
+	return str
+}
+
+// GetStackTrace function returns the stack trace attached to an error
+// returned by the VM. nil is returned if the error doesn't have one.
+func GetStackTrace(err error) StackTrace {
+	var se *SourceError
+	if errors.As(err, &se) {
+		return se.Stack
+	}
+	return nil
+}
+
+// evalState holds the state of an evaluation. It is shared by all the scopes
+// created from the same VM. A forked evaluation gets a state of its own.
+type evalState struct {
+	frames []Frame
+	// thrown is the exception (or the error) raised by an in-built function
+	thrown error
+	ctx    context.Context
+	done   <-chan struct{}
+	meter  *meter
+	active int
+	// imports holds the modules being loaded, the innermost last
+	imports []importFrame
+	// waiting is the module loaded by another evaluation, which this one is
+	// waiting for. imports and waiting are updated with the process control locked.
+	waiting *module
+}
+
+// newEvalState function returns a new evaluation state interrupted by the passed context
+func newEvalState(ctx context.Context) *evalState {
+	return &evalState{ctx: ctx, done: ctx.Done(), meter: &meter{}}
+}
+
+// interrupted method returns the error for the interruption of the evaluation
+// if the context is done, nil otherwise.
+func (st *evalState) interrupted() error {
+	switch st.ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return &cancelError{err: ErrDeadlineExceeded, cause: context.DeadlineExceeded}
+	}
+	return &cancelError{err: ErrSignalRecieved, cause: st.ctx.Err()}
+}
+
+// push method is used to add a call frame to the call stack
+func (st *evalState) push(name string, pos Pos) {
+	st.frames = append(st.frames, Frame{Name: name, Pos: pos})
+}
+
+// pop method is used to remove the innermost call frame from the call stack
+func (st *evalState) pop() {
+	st.frames = st.frames[:len(st.frames)-1]
+}
+
+// overflowFrames is the number of the innermost frames kept in the stack trace
+// of a stack overflow exception
+const overflowFrames = 16
+
+// SetMaxCallDepth method is used to set the maximum depth of the nested function
+// calls in an evaluation. A deeper call throws an exception of the kind
+// "stack overflow" instead of crashing the process. 0 means no limit.
+// The limit is DefaultMaxCallDepth for a new VM. It is read by the evaluations
+// without a lock, so it should be set before the VM is shared by the goroutines.
+func (vm *VM) SetMaxCallDepth(depth int) {
+	vm.pc.maxDepth = depth
+}
+
+// pushFrame method is used to add a call frame to the call stack, checking the
+// maximum call depth. A stack overflow exception with the innermost frames of
+// the call stack is returned when the limit is reached.
+func (vm *VM) pushFrame(name string, pos Pos) error {
+	limit := vm.pc.maxDepth
+	if limit <= 0 || len(vm.state.frames) < limit {
+		vm.state.push(name, pos)
+		return nil
+	}
+	e := &Exception{
+		Kind:    StackOverflowExceptionKind,
+		Message: fmt.Sprintf("maximum call depth of %d exceeded calling %s", limit, name),
+		Payload: Variable{Type: TypeInt, Value: int64(limit)},
+		Pos:     pos,
+		Stack:   StackTrace{{Name: name, Pos: pos}},
+	}
+	frames := vm.state.frames
+	for i := len(frames) - 1; i >= 0 && len(e.Stack) < overflowFrames; i-- {
+		e.Stack = append(e.Stack, frames[i])
+	}
+	return e
+}
+
+// trace method returns a copy of the current call stack with the innermost call first
+func (st *evalState) trace() StackTrace {
+	if len(st.frames) == 0 {
+		return nil
+	}
+	trace := make(StackTrace, 0, len(st.frames))
+	for i := len(st.frames) - 1; i >= 0; i-- {
+		trace = append(trace, st.frames[i])
+	}
+	return trace
+}
@@ -0,0 +1,286 @@
+package ligo
+
+import (
+	"strings"
+	"sync"
+)
+
+// fieldsLock guards the members of the structs and records, as they can be set
+// in place while the struct is used by another goroutine. The members are only
+// read through member, copyFields, fieldsSize and StructFields, which take it.
+var fieldsLock sync.RWMutex
+
+// member function returns the member of the struct (or record) fields
+func member(fields map[string]Variable, key string) (Variable, bool) {
+	fieldsLock.RLock()
+	defer fieldsLock.RUnlock()
+	v, ok := fields[key]
+	return v, ok
+}
+
+// copyFields function returns a shallow copy of the struct (or record) fields
+func copyFields(fields map[string]Variable) map[string]Variable {
+	fieldsLock.RLock()
+	defer fieldsLock.RUnlock()
+	copied := make(map[string]Variable, len(fields))
+	for key, val := range fields {
+		copied[key] = val
+	}
+	return copied
+}
+
+// StructFields function returns a copy of the members of the passed struct or
+// record, and whether it is one. The go packages should read the members through
+// it, as they can be set in place by another goroutine.
+func StructFields(v Variable) (map[string]Variable, bool) {
+	fields, err := structFields(v, "")
+	if err != nil {
+		return nil, false
+	}
+	return copyFields(fields), true
+}
+
+// fieldPath function is used to split a struct member path like p:Address:City
+// into the names in it.
+func fieldPath(name string) ([]string, error) {
+	parts := strings.Split(name, ":")
+	for _, part := range parts {
+		if !rVariable.MatchString(part) {
+			return nil, Error("Wrong token found in the struct member path " + name)
+		}
+	}
+	return parts, nil
+}
+
+// structFields function returns the members of the passed struct or record.
+// key is the member looked for, used in the error.
+func structFields(strct Variable, key string) (map[string]Variable, error) {
+	switch val := strct.Value.(type) {
+	case map[string]Variable:
+		if strct.Type == TypeStruct {
+			return val, nil
+		}
+	case *Record:
+		if strct.Type == TypeRecord {
+			return val.Fields, nil
+		}
+	}
+	return nil, Error("passed variable is not a struct and doesn't have a member named '" + key + "'")
+}
+
+// setField method is used to set the value of the struct member in the passed path
+// (like p:Address:City) in place. So every variable holding the struct sees the change.
+// Only the members already in the struct can be set.
+func (vm *VM) setField(name string, v Variable) (Variable, error) {
+	parts, err := fieldPath(name)
+	if err != nil {
+		return ligoNil, err
+	}
+	varName, path := parts[0], parts[1:]
+	strct, _, ok := vm.lookup(varName)
+	if !ok {
+		return ligoNil, Error("Variable '" + varName + "' not defined. Try \"var\" for creating a new variable")
+	}
+	fieldsLock.Lock()
+	defer fieldsLock.Unlock()
+	for i, key := range path {
+		fields, err := structFields(strct, key)
+		if err != nil {
+			return ligoNil, err
+		}
+		member, ok := fields[key]
+		if !ok {
+			return ligoNil, Error("no such key found in the struct : \"" + strings.Join(path[i:], ":") + "\"")
+		}
+		if i == len(path)-1 {
+			fields[key] = v
+			break
+		}
+		strct = member
+	}
+	return ligoNil, nil
+}
+
+// withField function returns a copy of the struct with the member in the passed
+// path set to the value. The structs in the path are copied too, the struct
+// passed is left as it is.
+func withField(strct Variable, path []string, v Variable) (Variable, error) {
+	fields, err := structFields(strct, path[0])
+	if err != nil {
+		return ligoNil, err
+	}
+	old, ok := member(fields, path[0])
+	if !ok {
+		return ligoNil, Error("no such key found in the struct : \"" + strings.Join(path, ":") + "\"")
+	}
+	if len(path) > 1 {
+		v, err = withField(old, path[1:], v)
+		if err != nil {
+			return ligoNil, err
+		}
+	}
+	copied := copyFields(fields)
+	copied[path[0]] = v
+	return withMembers(strct, copied), nil
+}
+
+// structWith method is used to run the struct-with construct. A copy of the
+// struct is returned with the passed members (which can be paths like Address:City)
+// set to the new values.
+func (vm *VM) structWith(tkns []Node) (Variable, error) {
+	if len(tkns) < 4 || len(tkns)%2 != 0 {
+		return ligoNil, Error("struct-with : expected (struct-with STRUCT MEMBER VALUE...)")
+	}
+	strct, err := vm.eval(tkns[1])
+	if err != nil {
+		return ligoNil, err
+	}
+	for i := 2; i < len(tkns); i += 2 {
+		key := tkns[i].String()
+		path, err := fieldPath(key)
+		if err != nil {
+			return ligoNil, err
+		}
+		val, err := vm.eval(tkns[i+1])
+		if err != nil {
+			return ligoNil, err
+		}
+		strct, err = withField(strct, path, val)
+		if err != nil {
+			return ligoNil, err
+		}
+	}
+	if err := vm.alloc(strct); err != nil {
+		return ligoNil, err
+	}
+	return strct, nil
+}
+
+// RecordType is a named record type declared with defstruct (or deftype).
+// The values of the type are records having exactly the declared fields.
+type RecordType struct {
+	Name   string
+	Fields []string
+}
+
+// Record is a value of a named record type. The fields are accessed and set
+// like the members of a struct, so they should be read with StructFields.
+type Record struct {
+	Type   *RecordType
+	Fields map[string]Variable
+}
+
+// isInstance method reports whether the passed value is a record of the type
+func (rt *RecordType) isInstance(v Variable) bool {
+	r, ok := v.Value.(*Record)
+	return ok && v.Type == TypeRecord && r.Type == rt
+}
+
+// predicate method returns the in-built function checking whether the passed
+// value is a record of the type, bound as NAME?
+func (rt *RecordType) predicate() InBuilt {
+	return func(vm *VM, a ...Variable) Variable {
+		if len(a) != 1 {
+			return vm.Throw(rt.Name + "? : expected 1 argument")
+		}
+		return Variable{Type: TypeBool, Value: rt.isInstance(a[0])}
+	}
+}
+
+// accessor method returns the in-built function returning the passed field of
+// a record of the type, bound as NAME-FIELD
+func (rt *RecordType) accessor(field string) InBuilt {
+	return func(vm *VM, a ...Variable) Variable {
+		if len(a) != 1 || !rt.isInstance(a[0]) {
+			return vm.Throw(rt.Name + "-" + field + " : expected a " + rt.Name + " as the only argument")
+		}
+		v, _ := member(a[0].Value.(*Record).Fields, field)
+		return v
+	}
+}
+
+// withMembers function returns a struct (or a record of the same type as the
+// passed one) having the passed members
+func withMembers(strct Variable, fields map[string]Variable) Variable {
+	if r, ok := strct.Value.(*Record); ok {
+		return Variable{Type: TypeRecord, Value: &Record{Type: r.Type, Fields: fields}}
+	}
+	return Variable{Type: TypeStruct, Value: fields}
+}
+
+// defStruct method is used to declare a named record type with the passed fields.
+// The type is bound to the name and is called like (NAME FIELD VALUE...) to
+// create a record. The predicate NAME? and the accessors NAME-FIELD are defined too.
+func (vm *VM) defStruct(tkns []Node) (Variable, error) {
+	form := tkns[0].String()
+	if len(tkns) < 3 {
+		return ligoNil, Error(form + " : expected (" + form + " NAME FIELD...)")
+	}
+	name, ok := tkns[1].(*Symbol)
+	if !ok || !rVariable.MatchString(name.Name) {
+		return ligoNil, Error(form + " : invalid type name " + tkns[1].String())
+	}
+	rt := &RecordType{Name: name.Name}
+	for _, n := range tkns[2:] {
+		field, ok := n.(*Symbol)
+		if !ok || !rVariable.MatchString(field.Name) {
+			return ligoNil, Error(form + " : invalid field name " + n.String())
+		}
+		for _, f := range rt.Fields {
+			if f == field.Name {
+				return ligoNil, Error(form + " : field " + f + " declared more than once")
+			}
+		}
+		rt.Fields = append(rt.Fields, field.Name)
+	}
+	vm.bind(rt.Name, Variable{Type: TypeRecordType, Value: rt})
+	vm.bind(rt.Name+"?", Variable{Type: TypeIFunc, Value: rt.predicate()})
+	for _, field := range rt.Fields {
+		vm.bind(rt.Name+"-"+field, Variable{Type: TypeIFunc, Value: rt.accessor(field)})
+	}
+	return ligoNil, nil
+}
+
+// newRecord method is used to create a record of the passed type from the
+// (FIELD VALUE...) arguments of the constructor call. Every field of the type
+// should be given, and only those.
+func (vm *VM) newRecord(rt *RecordType, tkns []Node) (Variable, error) {
+	if len(tkns)%2 != 0 {
+		return ligoNil, Error(rt.Name + " : expected (" + rt.Name + " FIELD VALUE...)")
+	}
+	fields := make(map[string]Variable, len(rt.Fields))
+	for i := 0; i < len(tkns); i += 2 {
+		key := tkns[i].String()
+		if !rt.hasField(key) {
+			return ligoNil, Error(rt.Name + " : unknown field " + key)
+		}
+		if _, ok := fields[key]; ok {
+			return ligoNil, Error(rt.Name + " : field " + key + " given more than once")
+		}
+		val, err := vm.eval(tkns[i+1])
+		if err != nil {
+			return ligoNil, err
+		}
+		fields[key] = val
+	}
+	for _, field := range rt.Fields {
+		if _, ok := fields[field]; !ok {
+			return ligoNil, Error(rt.Name + " : missing field " + field)
+		}
+	}
+	record := Variable{Type: TypeRecord, Value: &Record{Type: rt, Fields: fields}}
+	if err := vm.alloc(record); err != nil {
+		return ligoNil, err
+	}
+	return record, nil
+}
+
+// hasField method reports whether the type has the passed field
+func (rt *RecordType) hasField(name string) bool {
+	for _, field := range rt.Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package ligo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Task is the handle of an expression forked to run in a separate goroutine.
+// It holds the value (or the error) of the expression once it is done.
+type Task struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	value  Variable
+	err    error
+}
+
+// Done method returns a channel which is closed when the task is done
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Result method returns the value and the error of the task. It should be
+// called only after the task is done.
+func (t *Task) Result() (Variable, error) {
+	return t.value, t.err
+}
+
+// fork method is used to run the passed sub-expression in a separate go-routine.
+// A task is returned, which can be joined to get the value of the expression.
+func (vm *VM) fork(tkns []Node) (Variable, error) {
+	if len(tkns) != 2 {
+		return ligoNil, Error("fork : expected one expression, got " + fmt.Sprint(len(tkns)-1) + " arguments")
+	}
+	ctx, cancel := context.WithCancel(vm.state.ctx)
+	st := newEvalState(ctx)
+	st.meter, st.active = vm.state.meter, 1
+	t := runTask(vm.withState(st), tkns[1], cancel, nil)
+	return Variable{Type: TypeTask, Value: t}, nil
+}
+
+// runTask function is used to evaluate the node with the passed VM in a new
+// go-routine. cancel should cancel the context of the evaluation state of the VM.
+// onDone (if not nil) is called with the task once it is done.
+func runTask(vm *VM, n Node, cancel context.CancelFunc, onDone func(*Task)) *Task {
+	t := &Task{done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer func() {
+			close(t.done)
+			if onDone != nil {
+				onDone(t)
+			}
+		}()
+		defer cancel()
+		v, err := vm.evalGuarded([]Node{n})
+		if j, ok := err.(*jump); ok {
+			err = j.stray(vm.state)
+		}
+		t.value, t.err = v, err
+	}()
+	return t
+}
+
+// taskArgs function is used to check the arguments of the task functions, which
+// are tasks or arrays of tasks. The task of an actor can be passed as the actor.
+func taskArgs(form string, vars []Variable) ([]*Task, error) {
+	if len(vars) == 1 && vars[0].Type == TypeArray {
+		vars = vars[0].Value.([]Variable)
+	}
+	tasks := make([]*Task, 0, len(vars))
+	for _, v := range vars {
+		t, ok := v.Value.(*Task)
+		if a, isActor := v.Value.(*Actor); isActor && v.Type == TypeActor {
+			t, ok = a.task, a.task != nil
+		} else if v.Type != TypeTask {
+			ok = false
+		}
+		if !ok {
+			return nil, Error(form + " : expected a task, got " + v.GetTypeString())
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// singleTask function is used to check the only argument of a task function
+func singleTask(form string, vars []Variable) (*Task, error) {
+	if len(vars) != 1 {
+		return nil, Error(form + " : expected a task")
+	}
+	tasks, err := taskArgs(form, vars)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) != 1 {
+		return nil, Error(form + " : expected a task")
+	}
+	return tasks[0], nil
+}
+
+// wait method is used to wait for the task to be done. The wait is interrupted
+// if the current evaluation is cancelled.
+func (vm *VM) wait(t *Task) error {
+	select {
+	case <-t.done:
+		return nil
+	case <-vm.state.done:
+		return vm.state.interrupted()
+	}
+}
+
+// taskResult method returns the value of the done task. The exception thrown in
+// the task is thrown again, other errors are thrown as exceptions (of the kind
+// "cancelled" if the task was cancelled) with the position of the error in the
+// task. Exceeding the budget can't be caught.
+func (vm *VM) taskResult(t *Task) (Variable, error) {
+	if t.err == nil {
+		return t.value, nil
+	}
+	var e *Exception
+	if errors.As(t.err, &e) || errors.Is(t.err, ErrBudgetExceeded) {
+		return ligoNil, t.err
+	}
+	kind := DefaultExceptionKind
+	if errors.Is(t.err, context.Canceled) {
+		kind = CancelledExceptionKind
+	}
+	e = vm.newException(kind, t.err.Error(), ligoNil)
+	var se *SourceError
+	if errors.As(t.err, &se) {
+		e.Message, e.Pos, e.Stack = se.Err.Error(), se.Pos, se.Stack
+	}
+	return ligoNil, e
+}
+
+// join method is the join function. It waits for the task to be done and
+// returns it's value, or throws the error of it.
+func (vm *VM) join(vars ...Variable) Variable {
+	t, err := singleTask("join", vars)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	if err := vm.wait(t); err != nil {
+		return vm.raise(err)
+	}
+	v, err := vm.taskResult(t)
+	if err != nil {
+		return vm.raise(err)
+	}
+	return v
+}
+
+// joinAll method is the join-all function. It waits for all the tasks and
+// returns an array of their values. The first error (in the order of the
+// tasks) is thrown.
+func (vm *VM) joinAll(vars ...Variable) Variable {
+	tasks, err := taskArgs("join-all", vars)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	values := make([]Variable, 0, len(tasks))
+	for _, t := range tasks {
+		if err := vm.wait(t); err != nil {
+			return vm.raise(err)
+		}
+		v, err := vm.taskResult(t)
+		if err != nil {
+			return vm.raise(err)
+		}
+		values = append(values, v)
+	}
+	return Variable{Type: TypeArray, Value: values}
+}
+
+// joinAny method is the join-any function. It waits for the first of the tasks
+// to be done and returns it's value, or throws it's error.
+func (vm *VM) joinAny(vars ...Variable) Variable {
+	tasks, err := taskArgs("join-any", vars)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	if len(tasks) == 0 {
+		return vm.Throw("join-any : expected atleast one task")
+	}
+	cases := make([]reflect.SelectCase, 0, len(tasks)+1)
+	for _, t := range tasks {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.done)})
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(vm.state.done)})
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == len(tasks) {
+		return vm.raise(vm.state.interrupted())
+	}
+	v, err := vm.taskResult(tasks[chosen])
+	if err != nil {
+		return vm.raise(err)
+	}
+	return v
+}
+
+// taskDone method is the done? function, which reports whether the task is done
+// without waiting for it.
+func (vm *VM) taskDone(vars ...Variable) Variable {
+	t, err := singleTask("done?", vars)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	select {
+	case <-t.done:
+		return Variable{Type: TypeBool, Value: true}
+	default:
+		return Variable{Type: TypeBool, Value: false}
+	}
+}
+
+// cancelTask method is the cancel function. The evaluation of the task is
+// interrupted, and joining it throws an exception of the kind "cancelled".
+func (vm *VM) cancelTask(vars ...Variable) Variable {
+	t, err := singleTask("cancel", vars)
+	if err != nil {
+		return vm.Throw(err.Error())
+	}
+	t.cancel()
+	return ligoNil
+}
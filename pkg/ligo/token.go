@@ -9,9 +9,15 @@ func StripComments(ltxt string) string {
 	lines := strings.Split(ltxt, "\n")
 
 	inQuotes := false
+	isEscape := false
 	final := ""
 	for _, line := range lines {
 		for _, ch := range line {
+			if inQuotes && (isEscape || ch == '\\') {
+				isEscape = !isEscape
+				final += string(ch)
+				continue
+			}
 			if ch == '"' {
 				inQuotes = !inQuotes
 				final += string(ch)
@@ -27,48 +33,26 @@ func StripComments(ltxt string) string {
 	return final
 }
 
-// isValid function is used to check whether a string is a valid lisp expression
-func isValid(ltxt string) error {
-	if len(ltxt) < 2 {
-		return Error("Expected atleast (), got : " + ltxt)
-	}
-	if ltxt[0] != '(' {
-		return Error("Expected '(' at the start of the expression, got : " + string(ltxt[0]) + "\n" + ltxt)
-	}
-	if ltxt[len(ltxt)-1] != ')' {
-		return Error("Expected ')' at the end of the expression, got : " + string(ltxt[0]))
-	}
-
-	return nil
-}
-
 // ScanTokens is used to get token list from a passed ligo expression.
-// This is one of the important functions for parsing a ligo source code.
+// The expression is parsed into nodes and the source form of each node
+// inside the expression is returned.
 func ScanTokens(ltxt string) ([]string, error) {
-	ltxt = StripComments(ltxt)
-	ltxt = strings.TrimSpace(ltxt)
-
-	err := isValid(ltxt)
+	nodes, err := Parse(ltxt)
 	if err != nil {
 		return nil, err
 	}
-
-	p := newParser(ltxt)
-
-	for p.i = 1; p.i < len(p.ltxt); p.i++ {
-		c := string(p.ltxt[p.i])
-		err := p.runHandler(c)
-		if err != nil {
-			return nil, err
-		}
+	if len(nodes) != 1 {
+		return nil, Error("Expected a single expression, got : " + ltxt)
 	}
-	if p.inQuotes {
-		return nil, Error("Quote not closed correctly")
+	l, ok := nodes[0].(*List)
+	if !ok {
+		return nil, Error("Expected '(' at the start of the expression, got : " + ltxt)
 	}
-	if p.inSBkts {
-		return nil, Error("Closure not closed correctly")
+	tkns := make([]string, 0, len(l.Nodes))
+	for _, val := range l.Nodes {
+		tkns = append(tkns, val.String())
 	}
-	return p.strList, nil
+	return tkns, nil
 }
 
 // MatchChars function is used to return the offset at which the matching character of the passed character
@@ -83,6 +67,10 @@ func MatchChars(ltxt string, off int64, open byte, close byte) int64 {
 	count := 1
 	inQuotes := false
 	for i := off + 1; i < int64(len(ltxt)); i++ {
+		if inQuotes && ltxt[i] == '\\' {
+			i++
+			continue
+		}
 		if ltxt[i] == '"' {
 			inQuotes = !inQuotes
 		}
@@ -99,29 +87,31 @@ func MatchChars(ltxt string, off int64, open byte, close byte) int64 {
 	return -1
 }
 
-// getVarsFromClosure is used to extract all the parameter names from a
-// closure of a function definition in ligo
+// getVarsFromClosure is used to validate and extract all the parameter names
+// from a closure of a function definition in ligo
 // (ie., "|a b v r|" yields an array containing "a", "b", "v" and "r")
-func getVarsFromClosure(cl string) []string {
-	current := ""
-	retParams := make([]string, 0)
-	for _, val := range cl {
-		if strings.TrimSpace(string(val)) == "" || val == '|' {
-			if current == "" {
-				continue
+func getVarsFromClosure(n Node) ([]string, error) {
+	cl, ok := n.(*Closure)
+	if !ok {
+		return nil, Error("Expected a closure for the parameters, got : " + n.String())
+	}
+	for i, val := range cl.Params {
+		if isVariate(val) {
+			if i != len(cl.Params)-1 {
+				return nil, Error("the variate parameter should be at the end of the closure : " + cl.String())
 			}
-			retParams = append(retParams, current)
-			current = ""
-			continue
+			val = val[3:]
+		}
+		if !rVariable.MatchString(val) {
+			return nil, Error("Expected parameter name in the closure, got : " + val)
 		}
-		current += string(val)
 	}
-	return retParams
+	return cl.Params, nil
 }
 
 // isVariate is used to check whether a given token string is passed as a variate parameter.
 func isVariate(str string) bool {
-	if len(str) > 4 && str[:3] == "..." && str[3] != '.' {
+	if len(str) > 3 && str[:3] == "..." && str[3] != '.' {
 		return true
 	}
 	return false
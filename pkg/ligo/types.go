@@ -0,0 +1,163 @@
+package ligo
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// TypeInfo describes a type added to the VM by a go package with RegisterType.
+// Only the name is required, the rest fall back to the go behaviour of the values.
+type TypeInfo struct {
+	// Name is the name of the type, returned by GetTypeString (and the type function)
+	Name string
+	// Format returns the string form of a value of the type, used for printing.
+	// fmt.Sprint of the value is used if it is nil.
+	Format func(value interface{}) string
+	// Equal reports whether two values of the type are equal.
+	// The values are compared with == if it is nil.
+	Equal func(a, b interface{}) bool
+	// Hash returns a comparable key standing for the value, so that values which
+	// are Equal have the same key. It is used when the value is a key of a map.
+	// The value itself is the key if it is nil.
+	Hash func(value interface{}) interface{}
+	// Close releases the resources held by the value. It is run by the Close
+	// function, or when the value is garbage collected if it was created with
+	// NewValue and not closed.
+	Close func(value interface{}) error
+}
+
+// typeRegistry holds the types registered by the go packages
+var typeRegistry = struct {
+	sync.RWMutex
+	types map[Type]*TypeInfo
+	names map[string]Type
+	next  Type
+}{
+	types: make(map[Type]*TypeInfo),
+	names: make(map[string]Type),
+	next:  typeRegistered,
+}
+
+// RegisterType function is used to add a new type to the VM and returns the Type
+// allocated for it, which is unique in the process. It should be called once for
+// a type, when the package is initialized (like in a package level variable of a
+// plugin), as the plugin init function is run for every VM requiring it.
+// Registering a name already registered panics.
+func RegisterType(info TypeInfo) Type {
+	if info.Name == "" {
+		panic("ligo: RegisterType called without a type name")
+	}
+	typeRegistry.Lock()
+	defer typeRegistry.Unlock()
+	if _, ok := typeRegistry.names[info.Name]; ok {
+		panic("ligo: type " + info.Name + " registered more than once")
+	}
+	t := typeRegistry.next
+	typeRegistry.next++
+	typeRegistry.types[t] = &info
+	typeRegistry.names[info.Name] = t
+	return t
+}
+
+// LookupType function returns the description of a type registered with RegisterType
+func LookupType(t Type) (TypeInfo, bool) {
+	info := registeredType(t)
+	if info == nil {
+		return TypeInfo{}, false
+	}
+	return *info, true
+}
+
+// registeredType function returns the description of the registered type, nil if
+// it is not registered
+func registeredType(t Type) *TypeInfo {
+	if t < typeRegistered {
+		return nil
+	}
+	typeRegistry.RLock()
+	defer typeRegistry.RUnlock()
+	return typeRegistry.types[t]
+}
+
+// NewValue function returns a value of the registered type. If the type has a
+// Close hook and the value is a pointer (to a newly allocated object), the hook is
+// run when the value is garbage collected without being closed.
+func NewValue(t Type, value interface{}) Variable {
+	info := registeredType(t)
+	if info != nil && info.Close != nil && value != nil && reflect.TypeOf(value).Kind() == reflect.Ptr {
+		closeHook := info.Close
+		runtime.SetFinalizer(value, func(v interface{}) {
+			closeHook(v)
+		})
+	}
+	return Variable{Type: t, Value: value}
+}
+
+// Close function is used to release the value of a registered type with the Close
+// hook of the type. The hook is not run again when the value is garbage collected.
+func Close(v Variable) error {
+	info := registeredType(v.Type)
+	if info == nil {
+		return Error("close : can't close a value of the type " + v.GetTypeString())
+	}
+	if info.Close == nil {
+		return nil
+	}
+	if v.Value != nil && reflect.TypeOf(v.Value).Kind() == reflect.Ptr {
+		runtime.SetFinalizer(v.Value, nil)
+	}
+	return info.Close(v.Value)
+}
+
+// FormatValue function returns the string form of a value of a registered type,
+// made by the Format function of the type. false is returned for the other values.
+func FormatValue(v Variable) (string, bool) {
+	info := registeredType(v.Type)
+	if info == nil {
+		return "", false
+	}
+	if info.Format == nil {
+		return fmt.Sprint(v.Value), true
+	}
+	return info.Format(v.Value), true
+}
+
+// Equal function reports whether the two values are equal. The Equal function of
+// a registered type is used for it's values, arrays are equal if their items are
+// and the other values which can't be compared with == (like maps) are compared
+// deeply. The rest are compared with ==.
+func Equal(a, b Variable) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	if info := registeredType(a.Type); info != nil && info.Equal != nil {
+		return info.Equal(a.Value, b.Value)
+	}
+	if x, ok := a.Value.([]Variable); ok {
+		y, ok := b.Value.([]Variable)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if a.Value != nil && !reflect.TypeOf(a.Value).Comparable() {
+		return reflect.DeepEqual(a.Value, b.Value)
+	}
+	return a == b
+}
+
+// MapKey function returns the key to be used in a Map for the passed value. It is
+// the value itself, except for the registered types having a Hash function.
+func MapKey(v Variable) Variable {
+	if info := registeredType(v.Type); info != nil && info.Hash != nil {
+		return Variable{Type: v.Type, Value: info.Hash(v.Value)}
+	}
+	return v
+}
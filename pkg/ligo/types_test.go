@@ -0,0 +1,155 @@
+package ligo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// point is the go value of the registered types of the tests
+type point struct {
+	x, y int
+}
+
+// handle is a value of a registered type holding a resource
+type handle struct {
+	closed int
+}
+
+var (
+	// pointType compares the points by their x alone
+	pointType = RegisterType(TypeInfo{
+		Name:   "test-point",
+		Format: func(v interface{}) string { return fmt.Sprintf("<%d>", v.(point).x) },
+		Equal:  func(a, b interface{}) bool { return a.(point).x == b.(point).x },
+		Hash:   func(v interface{}) interface{} { return v.(point).x },
+	})
+	handleType = RegisterType(TypeInfo{
+		Name: "test-handle",
+		Close: func(v interface{}) error {
+			v.(*handle).closed++
+			return nil
+		},
+	})
+	plainType = RegisterType(TypeInfo{Name: "test-plain"})
+)
+
+func TestRegisterType(t *testing.T) {
+	if pointType == handleType || pointType < typeRegistered {
+		t.Fatalf("got the types %v and %v", pointType, handleType)
+	}
+	info, ok := LookupType(pointType)
+	if !ok || info.Name != "test-point" {
+		t.Fatalf("LookupType : got %v, %v", info, ok)
+	}
+	if _, ok := LookupType(TypeInt); ok {
+		t.Fatal("LookupType : int is not a registered type")
+	}
+	if got := (Variable{Type: pointType, Value: point{1, 2}}).GetTypeString(); got != "test-point" {
+		t.Fatalf("GetTypeString : got %q", got)
+	}
+	for _, info := range []TypeInfo{{Name: "test-point"}, {}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterType(%q) : expected a panic", info.Name)
+				}
+			}()
+			RegisterType(info)
+		}()
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	if got, ok := FormatValue(Variable{Type: pointType, Value: point{3, 0}}); !ok || got != "<3>" {
+		t.Fatalf("got %q, %v", got, ok)
+	}
+	if got, ok := FormatValue(Variable{Type: plainType, Value: 7}); !ok || got != "7" {
+		t.Fatalf("got %q, %v", got, ok)
+	}
+	if _, ok := FormatValue(Variable{Type: TypeInt, Value: int64(7)}); ok {
+		t.Fatal("an int is formatted as a registered type")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	arr := func(items ...Variable) Variable { return Variable{Type: TypeArray, Value: items} }
+	one := Variable{Type: TypeInt, Value: int64(1)}
+	two := Variable{Type: TypeInt, Value: int64(2)}
+	tests := []struct {
+		name string
+		a, b Variable
+		want bool
+	}{
+		{"ints", one, one, true},
+		{"different ints", one, two, false},
+		{"different types", one, Variable{Type: TypeFloat, Value: float64(1)}, false},
+		{"registered Equal", Variable{Type: pointType, Value: point{1, 2}}, Variable{Type: pointType, Value: point{1, 3}}, true},
+		{"registered not Equal", Variable{Type: pointType, Value: point{1, 2}}, Variable{Type: pointType, Value: point{2, 2}}, false},
+		{"registered without Equal", Variable{Type: plainType, Value: 1}, Variable{Type: plainType, Value: 1}, true},
+		{"arrays", arr(one, two), arr(one, two), true},
+		{"different arrays", arr(one, two), arr(one), false},
+		{"arrays of registered values", arr(Variable{Type: pointType, Value: point{1, 2}}), arr(Variable{Type: pointType, Value: point{1, 5}}), true},
+		{"structs", Variable{Type: TypeStruct, Value: map[string]Variable{"a": one}}, Variable{Type: TypeStruct, Value: map[string]Variable{"a": one}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Equal(tt.a, tt.b); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchEqual(t *testing.T) {
+	vm := newTestVM(t)
+	vm.Funcs["point"] = func(vm *VM, a ...Variable) Variable {
+		return Variable{Type: pointType, Value: point{int(a[0].Value.(int64)), int(a[1].Value.(int64))}}
+	}
+	for code, want := range map[string]string{
+		`(match (point 1 2) (point 2 2) "two" (point 1 9) "one" _ "none")`: `"one"`,
+		`(match [1 2] [1] "short" [1 2] "pair" _ "none")`:                  `"pair"`,
+	} {
+		v, err := vm.Eval(code)
+		if err != nil {
+			t.Fatalf("%s : %v", code, err)
+		}
+		if got := source(t, v); got != want {
+			t.Errorf("%s : got %s, want %s", code, got, want)
+		}
+	}
+}
+
+func TestMapKey(t *testing.T) {
+	a := MapKey(Variable{Type: pointType, Value: point{1, 2}})
+	b := MapKey(Variable{Type: pointType, Value: point{1, 3}})
+	if a != b || a.Type != pointType {
+		t.Fatalf("the keys of equal points differ : %v, %v", a, b)
+	}
+	m := Map{a: {Type: TypeInt, Value: int64(1)}}
+	if _, ok := m[b]; !ok {
+		t.Fatal("the equal point is not found in the map")
+	}
+	one := Variable{Type: TypeInt, Value: int64(1)}
+	if MapKey(one) != one {
+		t.Fatal("the key of an int is not the int itself")
+	}
+}
+
+func TestClose(t *testing.T) {
+	h := &handle{}
+	v := NewValue(handleType, h)
+	if err := Close(v); err != nil {
+		t.Fatal(err)
+	}
+	if h.closed != 1 {
+		t.Fatalf("the hook ran %d times, want 1", h.closed)
+	}
+	if err := Close(Variable{Type: plainType, Value: 1}); err != nil {
+		t.Fatalf("closing a type without a hook : %v", err)
+	}
+	err := Close(Variable{Type: TypeInt, Value: int64(1)})
+	if err == nil || !strings.Contains(err.Error(), "int") {
+		t.Fatalf("expected an error closing an int, got %v", err)
+	}
+}